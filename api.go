@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jason0x43/go-toggl"
+)
+
+// Requests for Toggl API endpoints that aren't covered by go-toggl. Errors are
+// formatted the same way go-toggl formats them so that callers can treat both
+// sources the same way.
+
+// apiRequest makes an authenticated request to the Toggl API. If body is
+// non-nil it's sent as JSON, and if result is non-nil the response is decoded
+// into it.
+func apiRequest(method, path string, body interface{}, result interface{}) (err error) {
+	var reader io.Reader
+	if body != nil {
+		var data []byte
+		if data, err = json.Marshal(body); err != nil {
+			return
+		}
+		dlog.Printf("%s %s: %s", method, path, data)
+		reader = bytes.NewReader(data)
+	} else {
+		dlog.Printf("%s %s", method, path)
+	}
+
+	var req *http.Request
+	if req, err = http.NewRequest(method, toggl.TogglAPI+path, reader); err != nil {
+		return
+	}
+	req.SetBasicAuth(config.APIKey, "api_token")
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error making request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Error reading body: %v", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("Response error: %s", resp.Status)
+	}
+
+	if result != nil && len(content) > 0 {
+		return json.Unmarshal(content, result)
+	}
+
+	return
+}
+
+// timeEntryRequest is the body of a time entry creation request
+type timeEntryRequest struct {
+	toggl.TimeEntry
+	CreatedWith string `json:"created_with"`
+}
+
+// createTimeEntry creates a time entry, including its project, tags, billable
+// flag, and start and stop times, in a single request.
+func createTimeEntry(entry toggl.TimeEntry) (created toggl.TimeEntry, err error) {
+	err = apiRequest(
+		"POST",
+		fmt.Sprintf("/workspaces/%d/time_entries", entry.Wid),
+		timeEntryRequest{TimeEntry: entry, CreatedWith: toggl.AppName},
+		&created,
+	)
+	return
+}
+
+// timeEntryUpdateRequest is the body of a time entry update request. The
+// project and task are always sent, even when they're nil, so that they can be
+// cleared.
+type timeEntryUpdateRequest struct {
+	toggl.TimeEntry
+	Pid *int `json:"project_id"`
+	Tid *int `json:"task_id"`
+}
+
+// putTimeEntry replaces a time entry's values with those of the given entry
+func putTimeEntry(entry toggl.TimeEntry) (updated toggl.TimeEntry, err error) {
+	err = apiRequest(
+		"PUT",
+		fmt.Sprintf("/workspaces/%d/time_entries/%d", entry.Wid, entry.ID),
+		timeEntryUpdateRequest{TimeEntry: entry, Pid: entry.Pid, Tid: entry.Tid},
+		&updated,
+	)
+	return
+}
+
+// updateClient renames a client
+func updateClient(client toggl.Client) (updated toggl.Client, err error) {
+	err = apiRequest(
+		"PUT",
+		fmt.Sprintf("/workspaces/%d/clients/%d", client.Wid, client.ID),
+		map[string]interface{}{"name": client.Name},
+		&updated,
+	)
+	return
+}
+
+// archiveClient archives a client. Toggl also archives the client's projects,
+// and their IDs are returned.
+func archiveClient(client toggl.Client) (projectIDs []int, err error) {
+	err = apiRequest(
+		"POST",
+		fmt.Sprintf("/workspaces/%d/clients/%d/archive", client.Wid, client.ID),
+		nil,
+		&projectIDs,
+	)
+	return
+}
+
+// projectDetails holds project settings that go-toggl doesn't expose
+type projectDetails struct {
+	ID             int      `json:"id"`
+	Color          string   `json:"color"`
+	EstimatedHours *int     `json:"estimated_hours"`
+	Rate           *float64 `json:"rate"`
+	Currency       *string  `json:"currency"`
+	IsPrivate      bool     `json:"is_private"`
+	ActualSeconds  *int64   `json:"actual_seconds"`
+
+	// Fetched is when ActualSeconds was downloaded; it isn't a Toggl field
+	Fetched time.Time `json:"fetched,omitempty"`
+}
+
+// workspaceDetails holds the billing settings of a workspace, which aren't
+// included in the account data
+type workspaceDetails struct {
+	ID                int      `json:"id"`
+	DefaultHourlyRate *float64 `json:"default_hourly_rate"`
+	DefaultCurrency   string   `json:"default_currency"`
+}
+
+// projectUpdateRequest is the body of a project update request that includes
+// the project's details
+type projectUpdateRequest struct {
+	toggl.Project
+	Color          string   `json:"color,omitempty"`
+	EstimatedHours *int     `json:"estimated_hours"`
+	Rate           *float64 `json:"rate"`
+	IsPrivate      bool     `json:"is_private"`
+}
+
+// getProjectDetails returns the details of all the user's projects, including
+// archived ones
+func getProjectDetails() (details []projectDetails, err error) {
+	if err = apiRequest("GET", "/me/projects?include_archived=true", nil, &details); err != nil {
+		return
+	}
+	now := time.Now()
+	for i := range details {
+		details[i].Fetched = now
+	}
+	return
+}
+
+// getWorkspaceDetails returns the billing settings for all of the user's
+// workspaces
+func getWorkspaceDetails() (details []workspaceDetails, err error) {
+	err = apiRequest("GET", "/me/workspaces", nil, &details)
+	return
+}
+
+// updateProjectWithDetails updates a project and its details in a single
+// request
+func updateProjectWithDetails(project toggl.Project, details projectDetails) (
+	updated toggl.Project, updatedDetails projectDetails, err error) {
+	var data json.RawMessage
+	if err = apiRequest(
+		"PUT",
+		fmt.Sprintf("/workspaces/%d/projects/%d", project.Wid, project.ID),
+		projectUpdateRequest{
+			Project:        project,
+			Color:          details.Color,
+			EstimatedHours: details.EstimatedHours,
+			Rate:           details.Rate,
+			IsPrivate:      details.IsPrivate,
+		},
+		&data,
+	); err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(data, &updated); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &updatedDetails); err != nil {
+		return
+	}
+	updatedDetails.Fetched = time.Now()
+	return
+}
+
+// projectTask is a task within a project. go-toggl's Task type uses the
+// workspace and project field names from an older API version.
+type projectTask struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Pid    int    `json:"project_id"`
+	Wid    int    `json:"workspace_id"`
+	Active bool   `json:"active"`
+}
+
+// getTasks returns the tasks in the user's workspaces
+func getTasks() (tasks []projectTask, err error) {
+	err = apiRequest("GET", "/me/tasks", nil, &tasks)
+	return
+}
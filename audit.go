@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jason0x43/go-alfred"
+	"github.com/jason0x43/go-toggl"
+)
+
+// AuditCommand is a command for finding problems in time entries
+type AuditCommand struct{}
+
+// About returns information about a command
+func (c AuditCommand) About() alfred.CommandDef {
+	return alfred.CommandDef{
+		Keyword:     "audit",
+		Description: "Find overlapping entries, gaps, and missing information",
+		IsEnabled:   config.APIKey != "",
+	}
+}
+
+// Items returns a list of filter items
+func (c AuditCommand) Items(arg, data string) (items []alfred.Item, err error) {
+	if err = checkRefresh(); err != nil {
+		return
+	}
+
+	var cfg auditCfg
+	if data != "" {
+		if err = json.Unmarshal([]byte(data), &cfg); err != nil {
+			dlog.Printf("Error unmarshalling data: %v", err)
+		}
+	}
+
+	if cfg.Span == nil {
+		for _, span := range matchSpans(arg) {
+			items = append(items, createAuditMenuItem(span))
+		}
+
+		if len(items) == 0 {
+			items = append(items, alfred.Item{
+				Title: "Enter a valid date or range",
+			})
+		}
+
+		return
+	}
+
+	span := *cfg.Span
+	if span.Start.IsZero() {
+		if span, err = getSpan(span.Name); err != nil {
+			return
+		}
+	}
+
+	findings, complete := auditTimeEntries(span)
+	for _, finding := range findings {
+		if alfred.FuzzyMatches(finding.Title, arg) {
+			items = append(items, finding)
+		}
+	}
+
+	name := span.Name
+	if span.Label != "" {
+		name = span.Label
+	}
+
+	if !complete && arg == "" {
+		items = alfred.InsertItem(items, alfred.Item{
+			Title:    "Incomplete audit for " + name,
+			Subtitle: "Some time entries couldn't be downloaded from toggl.com",
+		}, 0)
+	} else if len(items) == 0 && arg == "" {
+		items = append(items, alfred.Item{
+			Title: "No problems found for " + name,
+			Arg: &alfred.ItemArg{
+				Keyword: "audit",
+			},
+		})
+	}
+
+	return
+}
+
+// support -------------------------------------------------------------------
+
+type auditCfg struct {
+	Span *span `json:"span,omitempty"`
+}
+
+func createAuditMenuItem(s span) alfred.Item {
+	subtitle := "Audit time entries for "
+	if s.Label != "" {
+		subtitle += s.Label
+	} else {
+		subtitle += s.Name
+	}
+
+	return alfred.Item{
+		Autocomplete: s.Name,
+		Title:        s.Name,
+		Subtitle:     subtitle,
+		Arg: &alfred.ItemArg{
+			Keyword: "audit",
+			Data:    alfred.Stringify(&auditCfg{Span: &s}),
+		},
+	}
+}
+
+// auditTimeEntries returns an item for each problem found in the time entries
+// in a span. Actioning an item fixes the problem or opens the affected entry.
+// complete is false if some of the entries couldn't be downloaded.
+func auditTimeEntries(s span) (items []alfred.Item, complete bool) {
+	var entries []toggl.TimeEntry
+	entries, complete = getTimeEntries(s.Start, s.End)
+	sort.Sort(byTime(entries))
+
+	items = append(items, findOverlaps(entries)...)
+	items = append(items, findGaps(entries)...)
+	items = append(items, findIncompleteEntries(entries)...)
+
+	return
+}
+
+// findOverlaps returns items for time entries that start before an earlier
+// entry has ended. Actioning an item trims the earlier entry, or opens it if the
+// later entry lies entirely within it. Entries must be sorted by start time.
+func findOverlaps(entries []toggl.TimeEntry) (items []alfred.Item) {
+	if len(entries) == 0 {
+		return
+	}
+
+	// Each entry is compared to the earlier entry that ends last
+	prev := entries[0]
+
+	for _, next := range entries[1:] {
+		overlap := getEntryEnd(prev).Sub(next.StartTime())
+		if overlap <= 0 {
+			prev = next
+			continue
+		}
+
+		contained := !getEntryEnd(next).After(getEntryEnd(prev))
+		if contained {
+			overlap = getEntryEnd(next).Sub(next.StartTime())
+		}
+
+		item := alfred.Item{
+			Title: fmt.Sprintf(`Overlap: "%s" and "%s"`, describeEntry(prev), describeEntry(next)),
+			Subtitle: fmt.Sprintf("%s overlap on %s at %s", formatMinutes(overlap),
+				toHumanDateString(next.StartTime()), next.StartTime().Local().Format("15:04")),
+		}
+
+		if contained {
+			// Trimming the earlier entry would delete its time after the later
+			// one, so it's opened instead
+			item.Subtitle += fmt.Sprintf(`; "%s" is within "%s"`, describeEntry(next),
+				describeEntry(prev))
+			if openArg := getOpenEntryArg(prev); openArg != nil {
+				item.Subtitle += "; press Enter to edit it"
+				item.Arg = openArg
+			}
+		} else if !prev.IsRunning() {
+			trimmed := prev.Copy()
+			trimmed.SetStopTime(next.StartTime())
+			item.Subtitle += fmt.Sprintf(`; press Enter to end "%s" at %s`, describeEntry(prev),
+				next.StartTime().Local().Format("15:04"))
+			item.Arg = &alfred.ItemArg{
+				Keyword: "timers",
+				Mode:    alfred.ModeDo,
+				Data:    alfred.Stringify(timerCfg{ToUpdate: &trimmed}),
+			}
+		}
+
+		items = append(items, item)
+
+		if getEntryEnd(next).After(getEntryEnd(prev)) {
+			prev = next
+		}
+	}
+
+	return
+}
+
+// findGaps returns items for untracked periods during working hours that are
+// longer than the configured threshold. Days without any entries are assumed
+// to be days off. Actioning an item logs an entry for the gap that continues
+// the preceding entry.
+func findGaps(entries []toggl.TimeEntry) (items []alfred.Item) {
+	threshold := time.Duration(getAuditGapMinutes()) * time.Minute
+	now := time.Now()
+
+	byDay := map[string][]toggl.TimeEntry{}
+	var days []time.Time
+	for _, entry := range entries {
+		key := toIsoDateString(entry.StartTime().Local())
+		if _, ok := byDay[key]; !ok {
+			days = append(days, toDayStart(entry.StartTime()))
+		}
+		byDay[key] = append(byDay[key], entry)
+	}
+
+	for _, day := range days {
+		dayStart := day.Add(time.Duration(getWorkdayStartHour()) * time.Hour)
+		dayEnd := day.Add(time.Duration(getWorkdayEndHour()) * time.Hour)
+		if dayEnd.After(now) {
+			dayEnd = now
+		}
+
+		cursor := dayStart
+		var previous *toggl.TimeEntry
+
+		addGap := func(gapStart, gapEnd time.Time) {
+			if gapEnd.Sub(gapStart) < threshold {
+				return
+			}
+
+			toLog := startDesc{Start: &gapStart, Stop: &gapEnd}
+			subtitle := "Press Enter to log an entry for this gap"
+			if previous != nil {
+				toLog.Description = previous.Description
+				toLog.Tags = previous.Tags
+				toLog.Billable = previous.Billable
+				if previous.Pid != nil {
+					toLog.Pid = *previous.Pid
+				}
+				subtitle = fmt.Sprintf(`Press Enter to fill this gap with "%s"`, describeEntry(*previous))
+			}
+
+			items = append(items, alfred.Item{
+				Title: fmt.Sprintf("Gap: %s on %s from %s to %s", formatMinutes(gapEnd.Sub(gapStart)),
+					toHumanDateString(gapStart), gapStart.Format("15:04"), gapEnd.Format("15:04")),
+				Subtitle: subtitle,
+				Arg: &alfred.ItemArg{
+					Keyword: "timers",
+					Mode:    alfred.ModeDo,
+					Data:    alfred.Stringify(timerCfg{ToLog: &toLog}),
+				},
+			})
+		}
+
+		for i := range byDay[toIsoDateString(day)] {
+			entry := byDay[toIsoDateString(day)][i]
+			start := entry.StartTime().Local()
+			end := getEntryEnd(entry).Local()
+
+			if start.After(cursor) && cursor.Before(dayEnd) {
+				gapEnd := start
+				if gapEnd.After(dayEnd) {
+					gapEnd = dayEnd
+				}
+				addGap(cursor, gapEnd)
+			}
+
+			if end.After(cursor) {
+				cursor = end
+			}
+			previous = &entry
+		}
+
+		if cursor.Before(dayEnd) {
+			addGap(cursor, dayEnd)
+		}
+	}
+
+	return
+}
+
+// findIncompleteEntries returns items for time entries without a project or
+// description. Actioning an item opens the entry's property list.
+func findIncompleteEntries(entries []toggl.TimeEntry) (items []alfred.Item) {
+	for i := range entries {
+		entry := entries[i]
+
+		var missing string
+		if entry.Pid == nil && entry.Description == "" {
+			missing = "No project or description"
+		} else if entry.Pid == nil {
+			missing = "No project"
+		} else if entry.Description == "" {
+			missing = "No description"
+		} else {
+			continue
+		}
+
+		item := alfred.Item{
+			Title: fmt.Sprintf(`%s: "%s"`, missing, describeEntry(entry)),
+			Subtitle: fmt.Sprintf("Started %s at %s", toHumanDateString(entry.StartTime()),
+				entry.StartTime().Local().Format("15:04")),
+		}
+		if openArg := getOpenEntryArg(entry); openArg != nil {
+			item.Subtitle += "; press Enter to edit this entry"
+			item.Arg = openArg
+		}
+		items = append(items, item)
+	}
+
+	return
+}
+
+// getEntryEnd returns a time entry's stop time, or the current time for a
+// running entry
+func getEntryEnd(entry toggl.TimeEntry) time.Time {
+	if entry.IsRunning() {
+		return time.Now()
+	}
+	return entry.StopTime()
+}
+
+// getOpenEntryArg returns an arg that opens a time entry's property list, or
+// nil for an entry that's only in the history store and can't be edited here
+func getOpenEntryArg(entry toggl.TimeEntry) *alfred.ItemArg {
+	if _, _, ok := getTimerByID(entry.ID); !ok {
+		return nil
+	}
+	return &alfred.ItemArg{
+		Keyword: "timers",
+		Data:    alfred.Stringify(timerCfg{Timer: &entry.ID}),
+	}
+}
+
+func describeEntry(entry toggl.TimeEntry) string {
+	if entry.Description != "" {
+		return entry.Description
+	}
+	if entry.Pid != nil {
+		if project, _, ok := getProjectByID(*entry.Pid); ok {
+			return "[" + project.Name + "]"
+		}
+	}
+	return "<No description>"
+}
+
+func formatMinutes(d time.Duration) string {
+	minutes := int64(d.Round(time.Minute) / time.Minute)
+	if minutes >= 60 {
+		return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+func getAuditGapMinutes() int {
+	if config.AuditGapMinutes > 0 {
+		return config.AuditGapMinutes
+	}
+	return 15
+}
+
+// getWorkdayStartHour returns the configured start of the working day, which
+// may be 0 for midnight. New and older configs default to 9.
+func getWorkdayStartHour() int {
+	if config.WorkdayStartHour >= 0 && config.WorkdayStartHour < 24 {
+		return config.WorkdayStartHour
+	}
+	return 9
+}
+
+func getWorkdayEndHour() int {
+	if config.WorkdayEndHour > 0 {
+		return config.WorkdayEndHour
+	}
+	return 17
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jason0x43/go-toggl"
+)
+
+// Billable amounts use the BillableRate option if it's set, then the
+// project's hourly rate, then the workspace's default rate. Amounts are kept
+// separately for each currency.
+
+// billingTotals are the billable and non-billable parts of some amount of time,
+// in hours * 100
+type billingTotals struct {
+	billable    int64
+	nonBillable int64
+	amounts     map[string]float64
+}
+
+// add adds an entry's rounded duration to the totals. Amounts are based on the
+// rounded duration if rounding is enabled, and on the entry's exact length in
+// seconds otherwise.
+func (b *billingTotals) add(entry toggl.TimeEntry, duration, seconds int64) {
+	if !entry.Billable {
+		b.nonBillable += duration
+		return
+	}
+
+	b.billable += duration
+
+	rate, currency := getBillingRate(entry)
+	if rate == 0 {
+		return
+	}
+	if b.amounts == nil {
+		b.amounts = map[string]float64{}
+	}
+	hours := float64(seconds) / 3600.0
+	if config.Rounding != 0 {
+		hours = float64(duration) / 100.0
+	}
+	b.amounts[currency] += hours * rate
+}
+
+// String returns a description like "billable 3.00 (150.00 USD), non-billable
+// 1.50"
+func (b billingTotals) String() string {
+	billable := "billable " + formatDuration(b.billable)
+	if amounts := b.formatAmounts(); amounts != "" {
+		billable += " (" + amounts + ")"
+	}
+	return billable + ", non-billable " + formatDuration(b.nonBillable)
+}
+
+func (b billingTotals) formatAmounts() string {
+	var currencies []string
+	for currency := range b.amounts {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	var amounts []string
+	for _, currency := range currencies {
+		amount := fmt.Sprintf("%.2f", b.amounts[currency])
+		if currency != "" {
+			amount += " " + currency
+		}
+		amounts = append(amounts, amount)
+	}
+	return strings.Join(amounts, " + ")
+}
+
+// getBillingRate returns the hourly rate and currency for a billable entry. The
+// rate is 0 if none is set.
+func getBillingRate(entry toggl.TimeEntry) (rate float64, currency string) {
+	wid := entry.Wid
+	var details projectDetails
+	if entry.Pid != nil {
+		if project, _, ok := getProjectByID(*entry.Pid); ok {
+			wid = project.Wid
+		}
+		details, _, _ = getProjectDetailsByID(*entry.Pid)
+	}
+	workspace, _, _ := getWorkspaceDetailsByID(wid)
+
+	currency = workspace.DefaultCurrency
+	if details.Currency != nil && *details.Currency != "" {
+		currency = *details.Currency
+	}
+
+	if config.BillableRate > 0 {
+		return config.BillableRate, workspace.DefaultCurrency
+	}
+	if details.Rate != nil {
+		return *details.Rate, currency
+	}
+	if workspace.DefaultHourlyRate != nil {
+		return *workspace.DefaultHourlyRate, workspace.DefaultCurrency
+	}
+	return 0, currency
+}
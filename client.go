@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/jason0x43/go-alfred"
+	"github.com/jason0x43/go-toggl"
+)
+
+// ClientCommand is a command for handling clients
+type ClientCommand struct{}
+
+// About returns information about a command
+func (c ClientCommand) About() alfred.CommandDef {
+	return alfred.CommandDef{
+		Keyword:     "clients",
+		Description: "List your clients, add new ones",
+		IsEnabled:   config.APIKey != "",
+	}
+}
+
+// Items returns a list of filter items
+func (c ClientCommand) Items(arg, data string) (items []alfred.Item, err error) {
+	if err = checkRefresh(); err != nil {
+		return
+	}
+
+	var cfg clientCfg
+
+	if data != "" {
+		if err = json.Unmarshal([]byte(data), &cfg); err != nil {
+			dlog.Printf("Error unmarshalling clients var: %v", err)
+		}
+	}
+
+	if cfg.Client != nil {
+		// List menu for a client
+		if client, _, ok := getClientByID(*cfg.Client); ok {
+			return clientItems(client, arg)
+		}
+	}
+
+	for _, entry := range cache.Account.Clients {
+		if entry.Archived || !isVisibleWorkspace(entry.Wid) ||
+			!alfred.FuzzyMatches(entry.Name, arg) {
+			continue
+		}
+
+		id := entry.ID
+		projects := findProjectsByClientID(entry.ID)
+		subtitle := "1 project"
+		if len(projects) != 1 {
+			subtitle = fmt.Sprintf("%d projects", len(projects))
+		}
+
+		items = append(items, alfred.Item{
+			UID:          fmt.Sprintf("%s.client.%d", workflow.BundleID(), entry.ID),
+			Title:        entry.Name,
+			Subtitle:     subtitle,
+			Autocomplete: entry.Name,
+			Arg: &alfred.ItemArg{
+				Keyword: "clients",
+				Data:    alfred.Stringify(clientCfg{Client: &id}),
+			},
+		})
+	}
+
+	if len(items) == 0 && arg != "" {
+		items = append(items, alfred.Item{
+			Title:    arg,
+			Subtitle: "New client",
+			Arg: &alfred.ItemArg{
+				Keyword: "clients",
+				Mode:    alfred.ModeDo,
+				Data:    alfred.Stringify(clientCfg{ToCreate: &createClientMessage{Name: arg}}),
+			},
+		})
+	}
+
+	if len(items) == 0 {
+		items = append(items, alfred.Item{Title: "No matching clients"})
+	}
+
+	return
+}
+
+// Do runs the command
+func (c ClientCommand) Do(data string) (out string, err error) {
+	var cfg clientCfg
+
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+			dlog.Printf("Error unmarshaling client data: %v", err)
+		}
+	}
+
+	if cfg.ToCreate != nil {
+		dlog.Printf("creating client %v", cfg.ToCreate)
+		var client toggl.Client
+		if client, err = createClient(cfg.ToCreate); err != nil {
+			return
+		}
+		return fmt.Sprintf(`Created client "%s"`, client.Name), nil
+	}
+
+	if cfg.ToUpdate != nil {
+		dlog.Printf("updating client %v", cfg.ToUpdate)
+		var client toggl.Client
+		if client, err = renameClient(*cfg.ToUpdate); err != nil {
+			return
+		}
+		return fmt.Sprintf(`Renamed client to "%s"`, client.Name), nil
+	}
+
+	if cfg.ToArchive != nil {
+		dlog.Printf("archiving client %v", *cfg.ToArchive)
+		var client toggl.Client
+		if client, err = archiveClientByID(*cfg.ToArchive); err != nil {
+			return
+		}
+		return fmt.Sprintf(`Archived client "%s"`, client.Name), nil
+	}
+
+	return "Unrecognized input", nil
+}
+
+// support -------------------------------------------------------------------
+
+type clientCfg struct {
+	Client    *int                 `json:"client,omitempty"`
+	ToCreate  *createClientMessage `json:"create,omitempty"`
+	ToUpdate  *toggl.Client        `json:"update,omitempty"`
+	ToArchive *int                 `json:"archive,omitempty"`
+}
+
+type createClientMessage struct {
+	Name string
+	WID  int
+}
+
+func createClient(msg *createClientMessage) (client toggl.Client, err error) {
+	session := toggl.OpenSession(config.APIKey)
+
+	if msg.WID == 0 {
+		msg.WID = cache.Workspace
+	}
+
+	if client, err = session.CreateClient(msg.Name, msg.WID); err == nil {
+		cache.Account.Clients = append(cache.Account.Clients, client)
+		if err := saveCache(); err != nil {
+			dlog.Printf("Error saving cache: %s\n", err)
+		}
+	}
+
+	return
+}
+
+func renameClient(c toggl.Client) (client toggl.Client, err error) {
+	if client, err = updateClient(c); err != nil {
+		return
+	}
+
+	if _, index, ok := getClientByID(client.ID); ok {
+		cache.Account.Clients[index] = client
+		if err := saveCache(); err != nil {
+			dlog.Printf("Error saving cache: %s\n", err)
+		}
+	}
+
+	return
+}
+
+// archiveClientByID archives a client along with its projects
+func archiveClientByID(id int) (client toggl.Client, err error) {
+	var index int
+	var ok bool
+	if client, index, ok = getClientByID(id); !ok {
+		err = fmt.Errorf("Invalid client ID %d", id)
+		return
+	}
+
+	var projectIDs []int
+	if projectIDs, err = archiveClient(client); err != nil {
+		return
+	}
+
+	client.Archived = true
+	cache.Account.Clients[index] = client
+
+	for _, pid := range projectIDs {
+		if _, i, ok := getProjectByID(pid); ok {
+			cache.Account.Projects[i].Active = false
+		}
+	}
+
+	if err := saveCache(); err != nil {
+		dlog.Printf("Error saving cache: %s\n", err)
+	}
+
+	return
+}
+
+func findProjectsByClientID(cid int) (projects []toggl.Project) {
+	for _, project := range cache.Account.Projects {
+		if project.Cid != nil && *project.Cid == cid && project.IsActive() {
+			projects = append(projects, project)
+		}
+	}
+	return
+}
+
+func clientItems(client toggl.Client, arg string) (items []alfred.Item, err error) {
+	if alfred.FuzzyMatches("name:", arg) {
+		item := alfred.Item{}
+		_, name := alfred.SplitCmd(arg)
+
+		if name != "" {
+			updateEntry := client
+			updateEntry.Name = name
+			item.Title = fmt.Sprintf("Change name to '%s'", name)
+			item.Subtitle = "Name: " + client.Name
+			item.Arg = &alfred.ItemArg{
+				Keyword: "clients",
+				Mode:    alfred.ModeDo,
+				Data:    alfred.Stringify(clientCfg{ToUpdate: &updateEntry}),
+			}
+		} else {
+			item.Title = "Name: " + client.Name
+			item.Autocomplete = "Name: "
+		}
+
+		items = append(items, item)
+	}
+
+	if alfred.FuzzyMatches("archive", arg) {
+		items = append(items, alfred.Item{
+			Title:        "Archive",
+			Subtitle:     "Archive this client and its projects",
+			Autocomplete: "Archive",
+			Arg: &alfred.ItemArg{
+				Keyword: "clients",
+				Mode:    alfred.ModeDo,
+				Data:    alfred.Stringify(clientCfg{ToArchive: &client.ID}),
+			},
+		})
+	}
+
+	var projItems []alfred.Item
+	for _, project := range findProjectsByClientID(client.ID) {
+		if alfred.FuzzyMatches(project.Name, arg) {
+			id := project.ID
+			projItems = append(projItems, alfred.Item{
+				UID:          fmt.Sprintf("%s.project.%d", workflow.BundleID(), project.ID),
+				Title:        project.Name,
+				Subtitle:     "Project",
+				Autocomplete: project.Name,
+				Icon:         "off.png",
+				Arg: &alfred.ItemArg{
+					Keyword: "projects",
+					Data:    alfred.Stringify(projectCfg{Project: &id}),
+				},
+			})
+		}
+	}
+	sort.Sort(alfred.ByTitle(projItems))
+	items = append(items, projItems...)
+
+	return
+}
@@ -0,0 +1,519 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jason0x43/go-alfred"
+	"github.com/jason0x43/go-toggl"
+)
+
+type exportFormat string
+
+const (
+	exportCSV      exportFormat = "csv"
+	exportJSON     exportFormat = "json"
+	exportMarkdown exportFormat = "markdown"
+
+	// Detailed formats list every time entry rather than a summary
+	exportEntriesCSV exportFormat = "entries-csv"
+	exportICS        exportFormat = "ics"
+)
+
+// exportFormats are the formats offered in the export menu
+var exportFormats = []exportFormat{
+	exportCSV,
+	exportJSON,
+	exportMarkdown,
+	exportEntriesCSV,
+	exportICS,
+}
+
+type exportDestination string
+
+// copyOutputPrefix marks action output that the workflow should send to
+// Alfred's Copy to Clipboard output rather than show in a notification
+const copyOutputPrefix = "-copy "
+
+const (
+	exportToFile      exportDestination = "file"
+	exportToClipboard exportDestination = "clipboard"
+)
+
+// reportExport describes how a report should be exported. An empty format
+// shows the export menu.
+type reportExport struct {
+	Format      exportFormat      `json:"format,omitempty"`
+	Destination exportDestination `json:"destination,omitempty"`
+}
+
+// reportRow is one line of an exported report
+type reportRow struct {
+	Name    string  `json:"name"`
+	Hours   float64 `json:"hours"`
+	Running bool    `json:"running,omitempty"`
+}
+
+var exportFormatNames = map[exportFormat]string{
+	exportCSV:        "CSV",
+	exportJSON:       "JSON",
+	exportMarkdown:   "Markdown",
+	exportEntriesCSV: "CSV of time entries",
+	exportICS:        "iCalendar events",
+}
+
+var exportExtensions = map[exportFormat]string{
+	exportCSV:        "csv",
+	exportJSON:       "json",
+	exportMarkdown:   "md",
+	exportEntriesCSV: "csv",
+	exportICS:        "ics",
+}
+
+// exportMenuItems returns the items for choosing how to export a report
+func exportMenuItems(cfg reportCfg, arg string) (items []alfred.Item) {
+	for _, format := range exportFormats {
+		name := exportFormatNames[format]
+		what := "the report"
+		if isDetailedExport(format) {
+			what = "every time entry in the report"
+		}
+
+		for _, dest := range []exportDestination{exportToClipboard, exportToFile} {
+			title := "Copy as " + name
+			subtitle := "Copy " + what + " to the clipboard"
+			if dest == exportToFile {
+				title = "Save as " + name + "..."
+				subtitle = "Choose a file to save " + what + " to"
+			}
+
+			if !alfred.FuzzyMatches(title, arg) {
+				continue
+			}
+
+			exportCfg := cfg
+			exportCfg.Export = &reportExport{Format: format, Destination: dest}
+			items = append(items, alfred.Item{
+				Title:    title,
+				Subtitle: subtitle,
+				Arg: &alfred.ItemArg{
+					Keyword: "report",
+					Mode:    alfred.ModeDo,
+					Data:    alfred.Stringify(&exportCfg),
+				},
+			})
+		}
+	}
+
+	return
+}
+
+// exportReport writes the report described by cfg in the chosen format and
+// destination
+func exportReport(cfg reportCfg) (out string, err error) {
+	span := *cfg.Span
+	if span.Start.IsZero() {
+		if span, err = getSpan(span.Name); err != nil {
+			return
+		}
+	}
+
+	fileName := "toggl-" + span.Name + "." + exportExtensions[cfg.Export.Format]
+
+	if isDetailedExport(cfg.Export.Format) {
+		var entries []toggl.TimeEntry
+		if entries, err = getReportEntries(span, &cfg); err != nil {
+			return
+		}
+
+		var data []byte
+		if cfg.Export.Format == exportICS {
+			data = formatEntriesICS(entries)
+		} else if data, err = formatEntriesCSV(entries); err != nil {
+			return
+		}
+
+		name := fmt.Sprintf("%d time entries", len(entries))
+		if len(entries) == 1 {
+			name = "1 time entry"
+		}
+		return writeExport(data, name, fileName, cfg.Export.Destination)
+	}
+
+	var report *summaryReport
+	if report, err = generateReport(span.Start, span.End, &cfg); err != nil {
+		return
+	}
+	if !report.complete {
+		return "", incompleteExportError(span)
+	}
+
+	spanName := span.Name
+	if span.Label != "" {
+		spanName = span.Label
+	}
+	title := "Time for " + spanName + describeReportFilters(&cfg)
+	column, rows := getReportRows(report, &cfg)
+	total := float64(report.total) / 100.0
+
+	var data []byte
+	switch cfg.Export.Format {
+	case exportCSV:
+		data, err = formatReportCSV(column, rows, total)
+	case exportJSON:
+		data, err = formatReportJSON(title, span, column, rows, total)
+	case exportMarkdown:
+		data = formatReportMarkdown(title, column, rows, report.total)
+	default:
+		err = fmt.Errorf("Unknown export format '%s'", cfg.Export.Format)
+	}
+	if err != nil {
+		return
+	}
+
+	name := "report as " + exportFormatNames[cfg.Export.Format]
+	return writeExport(data, name, fileName, cfg.Export.Destination)
+}
+
+func isDetailedExport(format exportFormat) bool {
+	return format == exportEntriesCSV || format == exportICS
+}
+
+// incompleteExportError is returned instead of exporting a report that's
+// missing time entries
+func incompleteExportError(span span) error {
+	return fmt.Errorf("Some time entries for %s couldn't be downloaded; try exporting again later",
+		span.Name)
+}
+
+// getReportEntries returns the time entries in a report, sorted by start time.
+// It fails if some of the entries couldn't be downloaded.
+func getReportEntries(span span, cfg *reportCfg) (entries []toggl.TimeEntry, err error) {
+	projects := getProjectsByID()
+
+	all, complete := getTimeEntries(span.Start, span.End)
+	if !complete {
+		return nil, incompleteExportError(span)
+	}
+
+	for _, entry := range all {
+		if matchesReportFilters(entry, cfg, projects) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Sort(byTime(entries))
+	return
+}
+
+// getEntryDuration returns the length of an entry in seconds, including the
+// time so far for a running entry
+func getEntryDuration(entry toggl.TimeEntry) int64 {
+	if entry.Duration < 0 {
+		return round(time.Now().Sub(entry.StartTime()).Seconds())
+	}
+	return entry.Duration
+}
+
+// getEntryNames returns the names of an entry's project and client
+func getEntryNames(entry toggl.TimeEntry) (projectName, clientName string) {
+	if entry.Pid != nil {
+		if project, _, ok := getProjectByID(*entry.Pid); ok {
+			projectName = project.Name
+			if project.Cid != nil {
+				if client, _, ok := getClientByID(*project.Cid); ok {
+					clientName = client.Name
+				}
+			}
+		}
+	}
+	return
+}
+
+func formatEntriesCSV(entries []toggl.TimeEntry) (data []byte, err error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	writer.Write([]string{
+		"ID",
+		"Description",
+		"Project",
+		"Client",
+		"Tags",
+		"Billable",
+		"Start",
+		"Stop",
+		"Duration (seconds)",
+		"Rounded duration (hours)",
+	})
+
+	for _, entry := range entries {
+		projectName, clientName := getEntryNames(entry)
+		duration := getEntryDuration(entry)
+
+		// A running entry has no stop time yet
+		stop := ""
+		if entry.Duration >= 0 {
+			stop = entry.StopTime().Local().Format(time.RFC3339)
+		}
+
+		writer.Write([]string{
+			strconv.Itoa(entry.ID),
+			entry.Description,
+			projectName,
+			clientName,
+			strings.Join(entry.Tags, ", "),
+			strconv.FormatBool(entry.Billable),
+			entry.StartTime().Local().Format(time.RFC3339),
+			stop,
+			strconv.FormatInt(duration, 10),
+			fmt.Sprintf("%.2f", float64(roundDuration(duration, false))/100.0),
+		})
+	}
+
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// formatEntriesICS returns a calendar with an event for each time entry
+func formatEntriesICS(entries []toggl.TimeEntry) []byte {
+	const layout = "20060102T150405Z"
+	escape := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+	var lines []string
+	lines = append(lines,
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//jason0x43//alfred-toggl//EN",
+		"CALSCALE:GREGORIAN",
+	)
+
+	stamp := time.Now().UTC().Format(layout)
+	for _, entry := range entries {
+		projectName, clientName := getEntryNames(entry)
+		start := entry.StartTime()
+		stop := start.Add(time.Duration(getEntryDuration(entry)) * time.Second)
+
+		summary := entry.Description
+		if summary == "" {
+			summary = "(no description)"
+		}
+
+		var details []string
+		if projectName != "" {
+			details = append(details, "Project: "+projectName)
+		}
+		if clientName != "" {
+			details = append(details, "Client: "+clientName)
+		}
+		if len(entry.Tags) > 0 {
+			details = append(details, "Tags: "+strings.Join(entry.Tags, ", "))
+		}
+		if entry.Billable {
+			details = append(details, "Billable")
+		}
+
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:%d@toggl.com", entry.ID),
+			"DTSTAMP:"+stamp,
+			"DTSTART:"+start.UTC().Format(layout),
+			"DTEND:"+stop.UTC().Format(layout),
+			"SUMMARY:"+escape.Replace(summary),
+		)
+		if len(details) > 0 {
+			lines = append(lines, "DESCRIPTION:"+escape.Replace(strings.Join(details, "\n")))
+		}
+		if len(entry.Tags) > 0 {
+			var tags []string
+			for _, tag := range entry.Tags {
+				tags = append(tags, escape.Replace(tag))
+			}
+			lines = append(lines, "CATEGORIES:"+strings.Join(tags, ","))
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+
+	lines = append(lines, "END:VCALENDAR")
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(foldICSLine(line))
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes()
+}
+
+// foldICSLine splits a calendar line into lines of at most 75 bytes, without
+// breaking up UTF-8 characters
+func foldICSLine(line string) string {
+	var folded strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			folded.WriteString("\r\n ")
+			width = 1
+		}
+		folded.WriteRune(r)
+		width += size
+	}
+	return folded.String()
+}
+
+// getReportRows returns the rows shown by a report at the level described by
+// cfg, along with a name for them
+func getReportRows(report *summaryReport, cfg *reportCfg) (column string, rows []reportRow) {
+	var grouping reportGrouping
+	if cfg.Grouping != nil {
+		grouping = *cfg.Grouping
+	}
+
+	addRow := func(name string, total int64, running bool) {
+		rows = append(rows, reportRow{Name: name, Hours: float64(total) / 100.0, Running: running})
+	}
+
+	switch {
+	case grouping == groupByDay:
+		column = "Date"
+		var dates []*dateEntry
+		for _, date := range report.dates {
+			dates = append(dates, date)
+		}
+		sort.Slice(dates, func(i, j int) bool { return dates[i].date.Before(dates[j].date) })
+		for _, date := range dates {
+			addRow(date.name, date.total, false)
+		}
+		return
+
+	case grouping == groupByTag || grouping == groupByClient || grouping == groupByDescription:
+		column = map[reportGrouping]string{
+			groupByTag:         "Tag",
+			groupByClient:      "Client",
+			groupByDescription: "Description",
+		}[grouping]
+		for _, group := range report.getGroups(grouping) {
+			addRow(group.name, group.total, group.running)
+		}
+
+	case cfg.Project != nil:
+		column = "Description"
+		if grouping == groupByTask {
+			column = "Task"
+		}
+		for _, project := range report.projects {
+			entries := project.entries
+			if grouping == groupByTask {
+				entries = project.tasks
+			}
+			for name, entry := range entries {
+				addRow(name, entry.total, entry.running)
+			}
+		}
+
+	default:
+		column = "Project"
+		for _, project := range report.projects {
+			addRow(project.name, project.total, project.running)
+		}
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+	return
+}
+
+func formatReportCSV(column string, rows []reportRow, total float64) (data []byte, err error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	writer.Write([]string{column, "Hours"})
+	for _, row := range rows {
+		writer.Write([]string{row.Name, fmt.Sprintf("%.2f", row.Hours)})
+	}
+	writer.Write([]string{"Total", fmt.Sprintf("%.2f", total)})
+
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+func formatReportJSON(title string, span span, column string, rows []reportRow, total float64) ([]byte, error) {
+	return json.MarshalIndent(struct {
+		Title   string      `json:"title"`
+		Start   time.Time   `json:"start"`
+		End     time.Time   `json:"end"`
+		GroupBy string      `json:"groupBy"`
+		Rows    []reportRow `json:"rows"`
+		Total   float64     `json:"total"`
+	}{title, span.Start, span.End, strings.ToLower(column), rows, total}, "", "  ")
+}
+
+func formatReportMarkdown(title, column string, rows []reportRow, total int64) []byte {
+	escape := strings.NewReplacer("|", `\|`)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "## %s\n\n", title)
+	fmt.Fprintf(&buf, "| %s | Hours |\n", column)
+	fmt.Fprintf(&buf, "| --- | ---: |\n")
+	for _, row := range rows {
+		fmt.Fprintf(&buf, "| %s | %s |\n", escape.Replace(row.Name), formatDuration(round(row.Hours*100)))
+	}
+	fmt.Fprintf(&buf, "| **Total** | **%s** |\n", formatDuration(total))
+
+	return buf.Bytes()
+}
+
+// writeExport saves exported data to a file chosen by the user, or returns it
+// as output for the workflow to copy to the clipboard
+func writeExport(data []byte, name, fileName string, dest exportDestination) (out string, err error) {
+	if dest == exportToClipboard {
+		dlog.Printf("Copying %s to the clipboard", name)
+		return copyOutputPrefix + string(data), nil
+	}
+
+	var path string
+	if path, err = chooseExportFile(fileName); err != nil || path == "" {
+		return
+	}
+
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		return
+	}
+
+	return fmt.Sprintf("Saved %s to %s", name, path), nil
+}
+
+// chooseExportFile asks the user where to save a file. An empty path means the
+// user canceled.
+func chooseExportFile(defaultName string) (path string, err error) {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	defaultName = regexp.MustCompile(`[^\w.-]+`).ReplaceAllString(defaultName, "-")
+
+	script := fmt.Sprintf(
+		`tell application "Alfred %s"
+			activate
+			set exportFile to choose file name with prompt "Export to" default name "%s"
+			POSIX path of exportFile
+		end tell`,
+		os.Getenv("alfred_short_version"), escape.Replace(defaultName))
+
+	var response string
+	if response, err = alfred.RunScript(script); err != nil {
+		if strings.Contains(response, "User canceled") {
+			return "", nil
+		}
+		return
+	}
+
+	if path, err = strconv.Unquote(response); err != nil {
+		path, err = response, nil
+	}
+	return
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jason0x43/go-alfred"
+	"github.com/jason0x43/go-toggl"
+)
+
+// The account data only includes the last 9 days of time entries. Older
+// entries are kept in a local history store, which is filled in one date
+// range at a time as reports need it. Ranges are downloaded again once they're
+// older than historyTTL, which picks up changes made elsewhere.
+
+// historyTTL is how long downloaded history is used before it's refreshed
+const historyTTL = 7 * 24 * time.Hour
+
+// historyRange is a period, starting and ending at midnight, whose time
+// entries were downloaded at a given time
+type historyRange struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Fetched time.Time `json:"fetched"`
+}
+
+type historyStore struct {
+	Ranges  []historyRange    `json:"ranges"`
+	Entries []toggl.TimeEntry `json:"entries"`
+}
+
+// getTimeEntries returns the time entries that start within a period. Entries
+// from before the cached window are read from the history store, downloading
+// any that are missing. complete is false if some couldn't be downloaded.
+func getTimeEntries(since, until time.Time) (entries []toggl.TimeEntry, complete bool) {
+	complete = true
+	cached := map[int]bool{}
+
+	for _, entry := range cache.Account.TimeEntries {
+		start := entry.StartTime()
+		if !start.Before(since) && !until.Before(start) {
+			entries = append(entries, entry)
+			cached[entry.ID] = true
+		}
+	}
+
+	cacheStart := getCacheStart()
+	if !since.Before(cacheStart) {
+		return
+	}
+
+	start := toDayStart(since)
+	end := toDayStart(until).AddDate(0, 0, 1)
+	if cacheStart.Before(end) {
+		end = cacheStart
+	}
+
+	history := loadHistory()
+	if err := history.sync(start, end); err != nil {
+		dlog.Printf("Error syncing history: %v", err)
+		complete = false
+	}
+
+	for _, entry := range history.Entries {
+		entryStart := entry.StartTime()
+		if !cached[entry.ID] && !entryStart.Before(since) && entryStart.Before(end) &&
+			!until.Before(entryStart) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return
+}
+
+// getCacheStart returns the start of the period covered by the cached account
+// data
+func getCacheStart() time.Time {
+	return toDayStart(cache.Time).AddDate(0, 0, -8)
+}
+
+func loadHistory() (history historyStore) {
+	if err := alfred.LoadJSON(historyFile, &history); err != nil {
+		dlog.Printf("Error loading history: %v\n", err)
+	}
+	return
+}
+
+// sync downloads the time entries for the parts of a period that aren't in the
+// store yet. Entries are requested a month at a time; anything downloaded
+// before an error is kept. The downloads are added to the store as it is when
+// they're saved, so that changes made by other processes in the meantime
+// aren't lost.
+func (h *historyStore) sync(start, end time.Time) (err error) {
+	session := toggl.OpenSession(config.APIKey)
+
+	type download struct {
+		r       historyRange
+		entries []toggl.TimeEntry
+	}
+	var downloads []download
+
+	defer func() {
+		if len(downloads) > 0 {
+			if err := updateJSON(historyFile, h, func() {
+				for _, d := range downloads {
+					h.add(d.r, d.entries)
+				}
+			}); err != nil {
+				dlog.Printf("Error saving history: %v\n", err)
+			}
+		}
+	}()
+
+	for _, r := range h.missing(start, end) {
+		for chunkStart := r.Start; chunkStart.Before(r.End); {
+			chunkEnd := chunkStart.AddDate(0, 1, 0)
+			if r.End.Before(chunkEnd) {
+				chunkEnd = r.End
+			}
+
+			var entries []toggl.TimeEntry
+			if entries, err = session.GetTimeEntries(chunkStart, chunkEnd); err != nil {
+				return
+			}
+
+			dlog.Printf("Got %d history entries from %v to %v", len(entries), chunkStart, chunkEnd)
+			downloads = append(downloads, download{historyRange{chunkStart, chunkEnd, time.Now()},
+				entries})
+			chunkStart = chunkEnd
+		}
+	}
+
+	return
+}
+
+// missing returns the parts of a period that aren't covered by the store, or
+// that were downloaded too long ago
+func (h *historyStore) missing(start, end time.Time) (ranges []historyRange) {
+	for _, r := range h.Ranges {
+		if !r.End.After(start) || !r.Start.Before(end) ||
+			time.Now().Sub(r.Fetched) >= historyTTL {
+			continue
+		}
+		if start.Before(r.Start) {
+			ranges = append(ranges, historyRange{Start: start, End: r.Start})
+		}
+		if r.End.After(start) {
+			start = r.End
+		}
+	}
+
+	if start.Before(end) {
+		ranges = append(ranges, historyRange{Start: start, End: end})
+	}
+
+	return
+}
+
+// covers returns true if the store has downloaded the entries for a time
+func (h *historyStore) covers(t time.Time) bool {
+	for _, r := range h.Ranges {
+		if !t.Before(r.Start) && t.Before(r.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// add replaces the stored entries for a period with freshly downloaded ones
+func (h *historyStore) add(r historyRange, entries []toggl.TimeEntry) {
+	var kept []toggl.TimeEntry
+	for _, entry := range h.Entries {
+		start := entry.StartTime()
+		if start.Before(r.Start) || !start.Before(r.End) {
+			kept = append(kept, entry)
+		}
+	}
+	for _, entry := range entries {
+		start := entry.StartTime()
+		if !start.Before(r.Start) && start.Before(r.End) {
+			kept = append(kept, entry)
+		}
+	}
+	h.Entries = kept
+
+	// The new range replaces any parts of existing ranges that it overlaps,
+	// and ranges are kept sorted. Ranges aren't merged since each is refreshed
+	// according to when it was downloaded.
+	ranges := []historyRange{r}
+	for _, old := range h.Ranges {
+		if old.Start.Before(r.Start) {
+			before := old
+			if r.Start.Before(before.End) {
+				before.End = r.Start
+			}
+			ranges = append(ranges, before)
+		}
+		if r.End.Before(old.End) {
+			after := old
+			if after.Start.Before(r.End) {
+				after.Start = r.End
+			}
+			ranges = append(ranges, after)
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start.Before(ranges[j].Start) })
+
+	h.Ranges = ranges
+}
+
+// updateHistoryEntry applies a local change to a time entry from before the
+// cached window to the history store, so that reports include it without
+// waiting for its range to be downloaded again
+func updateHistoryEntry(entry toggl.TimeEntry, deleted bool) {
+	if !entry.StartTime().Before(getCacheStart()) {
+		return
+	}
+	if deleted {
+		updateHistoryEntries(nil, []toggl.TimeEntry{entry})
+	} else {
+		updateHistoryEntries([]toggl.TimeEntry{entry}, nil)
+	}
+}
+
+// updateHistoryEntries applies changed and deleted time entries to the history
+// store
+func updateHistoryEntries(changed, deleted []toggl.TimeEntry) {
+	if len(changed) == 0 && len(deleted) == 0 {
+		return
+	}
+
+	var history historyStore
+	if err := updateJSON(historyFile, &history, func() {
+		for _, entry := range changed {
+			history.apply(entry, false)
+		}
+		for _, entry := range deleted {
+			history.apply(entry, true)
+		}
+	}); err != nil {
+		dlog.Printf("Error saving history: %v\n", err)
+	}
+}
+
+// apply adds, updates or removes a stored entry. An entry outside the stored
+// ranges is left to be downloaded with the rest of its range.
+func (h *historyStore) apply(entry toggl.TimeEntry, deleted bool) {
+	var kept []toggl.TimeEntry
+	for _, e := range h.Entries {
+		if e.ID != entry.ID {
+			kept = append(kept, e)
+		}
+	}
+	if !deleted && h.covers(entry.StartTime()) {
+		kept = append(kept, entry)
+	}
+	h.Entries = kept
+}
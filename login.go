@@ -48,7 +48,7 @@ func (c LoginCommand) Do(data string) (out string, err error) {
 	}
 
 	config.APIKey = session.APIToken
-	if err = alfred.SaveJSON(configFile, &config); err != nil {
+	if err = saveConfig(); err != nil {
 		return
 	}
 
@@ -21,7 +21,7 @@ func (c LogoutCommand) About() alfred.CommandDef {
 // Do runs the command
 func (c LogoutCommand) Do(data string) (out string, err error) {
 	config.APIKey = ""
-	err = alfred.SaveJSON(configFile, &config)
+	err = saveConfig()
 	if err != nil {
 		return
 	}
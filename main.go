@@ -16,20 +16,40 @@ var dlog = log.New(os.Stderr, "[toggl] ", log.LstdFlags)
 
 var cacheFile string
 var configFile string
+var undoFile string
+var queueFile string
+var historyFile string
 var config struct {
-	APIKey           string `desc:"Toggl API key"`
-	AskForProject    bool   `desc:"If true, ask for a project if a default isn't set"`
-	DefaultProjectID int    `desc:"Optional default project ID for new time entries; set to 0 to clear"`
-	DurationOnly     bool   `desc:"If true, extend time entries instead of starting copies"`
-	HoursMinutes     bool   `desc:"If true, show hh:mm instead of fractional hours"`
-	Rounding         int    `desc:"Minutes to round to, 0 to disable rounding"`
-	NewTimerFirst    bool   `desc:"If true, show new timer before restart timer"`
-	TestMode         bool   `desc:"If true, disable auto refresh"`
+	APIKey            string  `desc:"Toggl API key"`
+	AskForProject     bool    `desc:"If true, ask for a project if a default isn't set"`
+	DefaultProjectID  int     `desc:"Optional default project ID for new time entries; set to 0 to clear"`
+	DurationOnly      bool    `desc:"If true, extend time entries instead of starting copies"`
+	HoursMinutes      bool    `desc:"If true, show hh:mm instead of fractional hours"`
+	Rounding          int     `desc:"Minutes to round to, 0 to disable rounding"`
+	NewTimerFirst     bool    `desc:"If true, show new timer before restart timer"`
+	TestMode          bool    `desc:"If true, disable auto refresh"`
+	AuditGapMinutes   int     `desc:"Shortest gap in minutes reported by audit (default 15)"`
+	WorkdayStartHour  int     `desc:"Hour the working day starts, used by audit (default 9)"`
+	WorkdayEndHour    int     `desc:"Hour the working day ends, used by audit (default 17)"`
+	AllWorkspaces     bool    `desc:"If true, show projects, tags and timers from every workspace"`
+	ShowArchived      bool    `desc:"If true, list archived projects"`
+	BudgetWarning     int     `desc:"Percentage of a project's estimate at which to warn (default 90)"`
+	RefreshInterval   int     `desc:"Minutes before cached data is refreshed (default 5)"`
+	BackgroundRefresh bool    `desc:"If true, show cached data at once and refresh it in the background"`
+	BillableRate      float64 `desc:"Hourly rate for all billable time, overriding project and workspace rates; 0 to disable"`
+	WorkspaceID       int
+	Version           int
 }
 var cache struct {
-	Workspace int
-	Account   toggl.Account
-	Time      time.Time
+	Version          int
+	Notice           string
+	Workspace        int
+	Account          toggl.Account
+	ProjectDetails   []projectDetails
+	WorkspaceDetails []workspaceDetails
+	Tasks            []projectTask
+	Sync             syncTimes
+	Time             time.Time
 }
 var workflow alfred.Workflow
 
@@ -49,26 +69,40 @@ func main() {
 
 	configFile = path.Join(workflow.DataDir(), "config.json")
 	cacheFile = path.Join(workflow.CacheDir(), "cache.json")
+	undoFile = path.Join(workflow.DataDir(), "undo.json")
+	queueFile = path.Join(workflow.DataDir(), "queue.json")
+	historyFile = path.Join(workflow.DataDir(), "history.json")
 
 	dlog.Printf("Using config file: %s", configFile)
 	dlog.Printf("Using cache file: %s", cacheFile)
 
-	if err := alfred.LoadJSON(configFile, &config); err != nil {
+	if err := loadConfig(); err != nil {
 		dlog.Println("Error loading config:", err)
 	}
 
-	if err := alfred.LoadJSON(cacheFile, &cache); err != nil {
+	if err := loadCache(); err != nil {
 		dlog.Println("Error loading cache:", err)
 	}
 
+	loadQueue()
+
+	if len(os.Args) > 1 && os.Args[1] == backgroundRefreshArg {
+		backgroundRefresh(os.Args[2:])
+		return
+	}
+
 	workflow.Run([]alfred.Command{
 		StatusFilter{},
 		LoginCommand{},
 		TokenCommand{},
 		TimeEntryCommand{},
 		ProjectCommand{},
+		ClientCommand{},
 		TagCommand{},
 		ReportFilter{},
+		AuditCommand{},
+		WorkspaceCommand{},
+		UndoCommand{},
 		OptionsCommand{},
 		LogoutCommand{},
 		ResetCommand{},
@@ -57,13 +57,8 @@ func (c OptionsCommand) Items(arg, data string) (items []alfred.Item, err error)
 				item.Title += " (press Enter to toggle)"
 			}
 
-			// copy the current options, update them, and use as the arg
-			opts := config
-			o := reflect.Indirect(reflect.ValueOf(&opts))
-			newVal := !f.Bool()
-			o.FieldByName(field.Name).SetBool(newVal)
 			item.Arg = itemArg
-			item.Arg.Data = alfred.Stringify(opts)
+			item.Arg.Data = optionData(field.Name, !f.Bool())
 			item.AddCheckBox(f.Bool())
 		case "int":
 			item.Autocomplete += " "
@@ -75,12 +70,8 @@ func (c OptionsCommand) Items(arg, data string) (items []alfred.Item, err error)
 				}
 				item.Title += fmt.Sprintf(": %d", val)
 
-				// copy the current options, update them, and use as the arg
-				opts := config
-				o := reflect.Indirect(reflect.ValueOf(&opts))
-				o.FieldByName(field.Name).SetInt(int64(val))
 				item.Arg = itemArg
-				item.Arg.Data = alfred.Stringify(opts)
+				item.Arg.Data = optionData(field.Name, val)
 			} else {
 				f := cfg.FieldByName(field.Name)
 				val := f.Int()
@@ -89,6 +80,25 @@ func (c OptionsCommand) Items(arg, data string) (items []alfred.Item, err error)
 					item.Title += " (type a new value to change)"
 				}
 			}
+		case "float64":
+			item.Autocomplete += " "
+
+			if value != "" {
+				val, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return items, err
+				}
+				item.Title += fmt.Sprintf(": %.2f", val)
+
+				item.Arg = itemArg
+				item.Arg.Data = optionData(field.Name, val)
+			} else {
+				f := cfg.FieldByName(field.Name)
+				item.Title += fmt.Sprintf(": %.2f", f.Float())
+				if name == field.Name {
+					item.Title += " (type a new value to change)"
+				}
+			}
 		case "string":
 			f := cfg.FieldByName(field.Name)
 			item.Autocomplete += " "
@@ -102,14 +112,25 @@ func (c OptionsCommand) Items(arg, data string) (items []alfred.Item, err error)
 
 // Do runs the command
 func (c OptionsCommand) Do(data string) (out string, err error) {
+	// data only holds the option being changed, so other options are left as
+	// they are
 	if err = json.Unmarshal([]byte(data), &config); err != nil {
 		return
 	}
 
-	if err = alfred.SaveJSON(configFile, &config); err != nil {
+	if err = saveConfig(); err != nil {
 		log.Printf("Error saving config: %s\n", err)
 		return "Error updating options", err
 	}
 
 	return "Updated options", err
 }
+
+// support -------------------------------------------------------------------
+
+// optionData returns the arg data for setting a single option. Only the one
+// option is included so that settings changed by other processes since the
+// options were listed aren't reverted.
+func optionData(name string, value interface{}) string {
+	return alfred.Stringify(map[string]interface{}{name: value})
+}
@@ -3,6 +3,10 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jason0x43/go-alfred"
 	"github.com/jason0x43/go-toggl"
@@ -57,7 +61,10 @@ func (c ProjectCommand) Items(arg, data string) (items []alfred.Item, err error)
 				clientName = client.Name
 			}
 
-			if entry.IsActive() && alfred.FuzzyMatches(entry.Name+clientName, arg) {
+			isListed := entry.IsActive() || (config.ShowArchived && entry.ServerDeletedAt == nil)
+
+			if isListed && isVisibleWorkspace(entry.Wid) &&
+				alfred.FuzzyMatches(entry.Name+clientName, arg) {
 				projectCfg.Project = &entry.ID
 
 				item := alfred.Item{
@@ -72,6 +79,17 @@ func (c ProjectCommand) Items(arg, data string) (items []alfred.Item, err error)
 					},
 				}
 
+				if !entry.Active {
+					item.Title += " (archived)"
+				}
+
+				if budget, ok := getProjectBudget(entry.ID); ok {
+					if item.Subtitle != "" {
+						item.Subtitle += ", "
+					}
+					item.Subtitle += budget.String()
+				}
+
 				if isRunning && runningTimer.Pid != nil && *runningTimer.Pid == entry.ID {
 					item.Icon = "running.png"
 				}
@@ -97,6 +115,23 @@ func (c ProjectCommand) Items(arg, data string) (items []alfred.Item, err error)
 		if len(items) == 0 {
 			items = append(items, alfred.Item{Title: "No matching projects"})
 		}
+
+		if alfred.FuzzyMatches("show archived", arg) {
+			c := config
+			c.ShowArchived = !config.ShowArchived
+			item := alfred.Item{
+				Title:        "Show archived",
+				Subtitle:     "Include archived projects in this list",
+				Autocomplete: "Show archived",
+				Arg: &alfred.ItemArg{
+					Keyword: "options",
+					Mode:    alfred.ModeDo,
+					Data:    alfred.Stringify(c),
+				},
+			}
+			item.AddCheckBox(config.ShowArchived)
+			items = append(items, item)
+		}
 	}
 
 	return items, nil
@@ -104,6 +139,8 @@ func (c ProjectCommand) Items(arg, data string) (items []alfred.Item, err error)
 
 // Do runs the command
 func (c ProjectCommand) Do(data string) (out string, err error) {
+	defer func() { saveUndoAction(out, err) }()
+
 	var cfg projectCfg
 
 	if data != "" {
@@ -115,7 +152,7 @@ func (c ProjectCommand) Do(data string) (out string, err error) {
 	if cfg.Default != nil {
 		dlog.Printf("setting default project to %v", cfg.Default)
 		config.DefaultProjectID = *cfg.Default
-		if err := alfred.SaveJSON(configFile, &config); err != nil {
+		if err := saveConfig(); err != nil {
 			return "Error saving config", err
 		}
 		return fmt.Sprintf(`Set default project to %d`, cfg.Default), nil
@@ -130,10 +167,34 @@ func (c ProjectCommand) Do(data string) (out string, err error) {
 		return fmt.Sprintf(`Created project "%s"`, project.Name), nil
 	}
 
+	if cfg.ToDelete != nil {
+		dlog.Printf("deleting project %v", *cfg.ToDelete)
+		var project toggl.Project
+		var ok bool
+		if project, _, ok = getProjectByID(*cfg.ToDelete); !ok {
+			err = fmt.Errorf(`Project %d does not exist`, *cfg.ToDelete)
+			return
+		}
+
+		if projectHasTimeEntries(project.ID) {
+			prompt := fmt.Sprintf(`Project "%s" has time entries, which will be left `+
+				`without a project. Delete it anyway?`, project.Name)
+			var confirmed bool
+			if confirmed, err = workflow.GetConfirmation(prompt, false); err != nil || !confirmed {
+				return
+			}
+		}
+
+		if err = deleteProject(project); err != nil {
+			return
+		}
+		return fmt.Sprintf(`Deleted project "%s"`, project.Name), nil
+	}
+
 	if cfg.ToUpdate != nil {
 		dlog.Printf("updating project %v", cfg.ToUpdate)
 		var project toggl.Project
-		if project, err = updateProject(cfg.ToUpdate); err != nil {
+		if project, err = updateProject(cfg.ToUpdate, cfg.Details); err != nil {
 			return
 		}
 		return fmt.Sprintf(`Updated project "%s"`, project.Name), nil
@@ -149,6 +210,8 @@ type projectCfg struct {
 	Default  *int                  `json:"default,omitempty"`
 	ToCreate *createProjectMessage `json:"create,omitempty"`
 	ToUpdate *toggl.Project        `json:"update,omitempty"`
+	ToDelete *int                  `json:"delete,omitempty"`
+	Details  *projectDetails       `json:"details,omitempty"`
 }
 
 type createProjectMessage struct {
@@ -160,12 +223,13 @@ func createProject(msg *createProjectMessage) (project toggl.Project, err error)
 	session := toggl.OpenSession(config.APIKey)
 
 	if msg.WID == 0 {
-		msg.WID = cache.Account.Workspaces[0].ID
+		msg.WID = cache.Workspace
 	}
 
 	if project, err = session.CreateProject(msg.Name, msg.WID); err == nil {
+		recordUndo(undoStep{Op: undoDeleteProject, Project: &project})
 		cache.Account.Projects = append(cache.Account.Projects, project)
-		if err := alfred.SaveJSON(cacheFile, &cache); err != nil {
+		if err := saveCache(); err != nil {
 			dlog.Printf("Error saving cache: %s\n", err)
 		}
 	}
@@ -173,19 +237,41 @@ func createProject(msg *createProjectMessage) (project toggl.Project, err error)
 	return
 }
 
-func updateProject(p *toggl.Project) (project toggl.Project, err error) {
+// updateProject updates a project. If details is non-nil, the project's details
+// are updated too.
+func updateProject(p *toggl.Project, details *projectDetails) (project toggl.Project, err error) {
 	session := toggl.OpenSession(config.APIKey)
+	original, _, hasOriginal := getProjectByID(p.ID)
+	originalDetails, _, hasDetails := getProjectDetailsByID(p.ID)
 
-	if project, err = session.UpdateProject(*p); err != nil {
+	if details != nil {
+		var updatedDetails projectDetails
+		if project, updatedDetails, err = updateProjectWithDetails(*p, *details); err != nil {
+			return
+		}
+		if _, index, ok := getProjectDetailsByID(project.ID); ok {
+			cache.ProjectDetails[index] = updatedDetails
+		} else {
+			cache.ProjectDetails = append(cache.ProjectDetails, updatedDetails)
+		}
+	} else if project, err = session.UpdateProject(*p); err != nil {
 		return
 	}
 
+	if hasOriginal {
+		step := undoStep{Op: undoRevertProject, Project: &original}
+		if details != nil && hasDetails {
+			step.Details = &originalDetails
+		}
+		recordUndo(step)
+	}
+
 	adata := &cache.Account
 
 	for i, p := range adata.Projects {
 		if p.ID == project.ID {
 			adata.Projects[i] = project
-			if err := alfred.SaveJSON(cacheFile, &cache); err != nil {
+			if err := saveCache(); err != nil {
 				dlog.Printf("Error saving cache: %v\n", err)
 			}
 			break
@@ -195,6 +281,37 @@ func updateProject(p *toggl.Project) (project toggl.Project, err error) {
 	return
 }
 
+// deleteProject deletes a project. Toggl keeps the project's time entries, so
+// they're detached from it in the cache.
+func deleteProject(project toggl.Project) (err error) {
+	session := toggl.OpenSession(config.APIKey)
+	if _, err = session.DeleteProject(project); err != nil {
+		return
+	}
+
+	adata := &cache.Account
+	if _, index, ok := getProjectByID(project.ID); ok {
+		adata.Projects = append(adata.Projects[:index], adata.Projects[index+1:]...)
+	}
+	for i := range adata.TimeEntries {
+		if adata.TimeEntries[i].Pid != nil && *adata.TimeEntries[i].Pid == project.ID {
+			adata.TimeEntries[i].Pid = nil
+		}
+	}
+	if err := saveCache(); err != nil {
+		dlog.Printf("Error saving cache: %s\n", err)
+	}
+
+	if config.DefaultProjectID == project.ID {
+		config.DefaultProjectID = 0
+		if err := saveConfig(); err != nil {
+			dlog.Printf("Error saving config: %s\n", err)
+		}
+	}
+
+	return
+}
+
 func projectItems(project toggl.Project, arg string) (items []alfred.Item, err error) {
 	if alfred.FuzzyMatches("name:", arg) {
 		item := alfred.Item{}
@@ -218,6 +335,58 @@ func projectItems(project toggl.Project, arg string) (items []alfred.Item, err e
 		items = append(items, item)
 	}
 
+	if alfred.FuzzyMatches("client:", arg) {
+		command, name := alfred.SplitCmd(arg)
+
+		if strings.ToLower(command) == "client:" {
+			for _, client := range cache.Account.Clients {
+				if client.Archived || client.Wid != project.Wid ||
+					!alfred.FuzzyMatches(client.Name, name) {
+					continue
+				}
+
+				isCurrent := project.Cid != nil && *project.Cid == client.ID
+				item := alfred.Item{
+					UID:          fmt.Sprintf("%s.client.%d", workflow.BundleID(), client.ID),
+					Title:        client.Name,
+					Autocomplete: "Client: " + client.Name,
+				}
+				item.AddCheckBox(isCurrent)
+
+				if !isCurrent {
+					cid := client.ID
+					updateEntry := project
+					updateEntry.Cid = &cid
+					item.Arg = &alfred.ItemArg{
+						Keyword: "projects",
+						Mode:    alfred.ModeDo,
+						Data:    alfred.Stringify(projectCfg{ToUpdate: &updateEntry}),
+					}
+				}
+
+				items = append(items, item)
+			}
+
+			alfred.FuzzySort(items, name)
+		} else {
+			item := alfred.Item{
+				Title:        "Client: <None>",
+				Subtitle:     "Assign this project to a client",
+				Autocomplete: "Client: ",
+			}
+			if project.Cid != nil {
+				if client, _, ok := getClientByID(*project.Cid); ok {
+					item.Title = "Client: " + client.Name
+				}
+			}
+			items = append(items, item)
+		}
+	}
+
+	if details, _, ok := getProjectDetailsByID(project.ID); ok {
+		items = append(items, projectDetailItems(project, details, arg)...)
+	}
+
 	if project.ID != config.DefaultProjectID {
 		if alfred.FuzzyMatches("Make default", arg) {
 			c := config
@@ -272,6 +441,55 @@ func projectItems(project toggl.Project, arg string) (items []alfred.Item, err e
 		items = append(items, item)
 	}
 
+	if project.Active {
+		if alfred.FuzzyMatches("archive", arg) {
+			updateEntry := project
+			updateEntry.Active = false
+			items = append(items, alfred.Item{
+				Title:        "Archive",
+				Subtitle:     "Hide this project from project lists",
+				Autocomplete: "Archive",
+				Arg: &alfred.ItemArg{
+					Keyword: "projects",
+					Mode:    alfred.ModeDo,
+					Data:    alfred.Stringify(projectCfg{ToUpdate: &updateEntry}),
+				},
+			})
+		}
+	} else {
+		if alfred.FuzzyMatches("unarchive", arg) {
+			updateEntry := project
+			updateEntry.Active = true
+			items = append(items, alfred.Item{
+				Title:        "Unarchive",
+				Subtitle:     "Make this project active again",
+				Autocomplete: "Unarchive",
+				Arg: &alfred.ItemArg{
+					Keyword: "projects",
+					Mode:    alfred.ModeDo,
+					Data:    alfred.Stringify(projectCfg{ToUpdate: &updateEntry}),
+				},
+			})
+		}
+	}
+
+	if alfred.FuzzyMatches("delete", arg) {
+		subtitle := "Delete this project"
+		if projectHasTimeEntries(project.ID) {
+			subtitle += "; its time entries will be kept"
+		}
+		items = append(items, alfred.Item{
+			Title:        "Delete",
+			Subtitle:     subtitle,
+			Autocomplete: "Delete",
+			Arg: &alfred.ItemArg{
+				Keyword: "projects",
+				Mode:    alfred.ModeDo,
+				Data:    alfred.Stringify(projectCfg{ToDelete: &project.ID}),
+			},
+		})
+	}
+
 	if alfred.FuzzyMatches("timers", arg) {
 		items = append(items, alfred.Item{
 			Title:        "Time entries...",
@@ -286,3 +504,243 @@ func projectItems(project toggl.Project, arg string) (items []alfred.Item, err e
 
 	return
 }
+
+// projectColors is Toggl's project color palette
+var projectColors = []struct {
+	Name string
+	Hex  string
+}{
+	{"Blue", "#0b83d9"},
+	{"Purple", "#9e5bd9"},
+	{"Pink", "#d94182"},
+	{"Orange", "#e36a00"},
+	{"Brown", "#bf7000"},
+	{"Green", "#2da608"},
+	{"Teal", "#06a893"},
+	{"Peach", "#c9806b"},
+	{"Indigo", "#465bb3"},
+	{"Magenta", "#990099"},
+	{"Yellow", "#c7af14"},
+	{"Olive", "#566614"},
+	{"Red", "#d92b2b"},
+	{"Gray", "#525266"},
+}
+
+func getColorName(hex string) string {
+	for _, color := range projectColors {
+		if strings.EqualFold(color.Hex, hex) {
+			return color.Name
+		}
+	}
+	return hex
+}
+
+// projectDetailItems returns property items for a project's color, estimate,
+// rate and privacy
+func projectDetailItems(project toggl.Project, details projectDetails, arg string) (items []alfred.Item) {
+	parts := alfred.CleanSplitN(arg, " ", 2)
+	command := strings.ToLower(parts[0])
+	var value string
+	if len(parts) > 1 {
+		value = parts[1]
+	}
+
+	updateArg := func(updated projectDetails) *alfred.ItemArg {
+		return &alfred.ItemArg{
+			Keyword: "projects",
+			Mode:    alfred.ModeDo,
+			Data:    alfred.Stringify(projectCfg{ToUpdate: &project, Details: &updated}),
+		}
+	}
+
+	if alfred.FuzzyMatches("color:", parts[0]) {
+		if command == "color:" {
+			for _, color := range projectColors {
+				if !alfred.FuzzyMatches(color.Name, value) {
+					continue
+				}
+				updated := details
+				updated.Color = color.Hex
+				item := alfred.Item{
+					Title:        color.Name,
+					Autocomplete: "Color: " + color.Name,
+					Arg:          updateArg(updated),
+				}
+				item.AddCheckBox(strings.EqualFold(color.Hex, details.Color))
+				items = append(items, item)
+			}
+		} else {
+			items = append(items, alfred.Item{
+				Title:        "Color: " + getColorName(details.Color),
+				Subtitle:     "Change the project's color",
+				Autocomplete: "Color: ",
+			})
+		}
+	}
+
+	if budget, ok := getProjectBudget(project.ID); ok && alfred.FuzzyMatches("budget", parts[0]) {
+		item := alfred.Item{
+			Title:    "Budget: " + budget.String(),
+			Subtitle: "Hours used against the estimate",
+		}
+		if budget.IsOverThreshold() {
+			item.Subtitle = fmt.Sprintf("Over the %d%% warning threshold", getBudgetWarning())
+		}
+		items = append(items, item)
+	}
+
+	if alfred.FuzzyMatches("estimate:", parts[0]) {
+		item := alfred.Item{
+			Title:        "Estimate: <None>",
+			Subtitle:     "Estimated hours; enter 0 to clear",
+			Autocomplete: "Estimate: ",
+		}
+		if details.EstimatedHours != nil {
+			item.Title = fmt.Sprintf("Estimate: %dh", *details.EstimatedHours)
+		}
+
+		if command == "estimate:" && value != "" {
+			if hours, err := strconv.Atoi(strings.TrimSuffix(value, "h")); err != nil || hours < 0 {
+				item.Title = fmt.Sprintf("Invalid estimate '%s'", value)
+			} else {
+				updated := details
+				if hours == 0 {
+					updated.EstimatedHours = nil
+					item.Title = "Clear estimate"
+				} else {
+					updated.EstimatedHours = &hours
+					item.Title = fmt.Sprintf("Change estimate to %dh", hours)
+				}
+				item.Arg = updateArg(updated)
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	if isWorkspacePremium(project.Wid) && alfred.FuzzyMatches("rate:", parts[0]) {
+		currency := ""
+		if details.Currency != nil {
+			currency = " " + *details.Currency
+		}
+
+		item := alfred.Item{
+			Title:        "Rate: <Workspace default>",
+			Subtitle:     "Hourly rate; enter 0 to use the workspace default",
+			Autocomplete: "Rate: ",
+		}
+		if details.Rate != nil {
+			item.Title = fmt.Sprintf("Rate: %.2f%s", *details.Rate, currency)
+		}
+
+		if command == "rate:" && value != "" {
+			if rate, err := strconv.ParseFloat(value, 64); err != nil || rate < 0 {
+				item.Title = fmt.Sprintf("Invalid rate '%s'", value)
+			} else {
+				updated := details
+				if rate == 0 {
+					updated.Rate = nil
+					item.Title = "Use the workspace's default rate"
+				} else {
+					updated.Rate = &rate
+					item.Title = fmt.Sprintf("Change rate to %.2f%s", rate, currency)
+				}
+				item.Arg = updateArg(updated)
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	if alfred.FuzzyMatches("private", parts[0]) {
+		updated := details
+		updated.IsPrivate = !details.IsPrivate
+		item := alfred.Item{
+			Title:        "Private",
+			Subtitle:     "Only project members can see a private project",
+			Autocomplete: "Private",
+			Arg:          updateArg(updated),
+		}
+		item.AddCheckBox(details.IsPrivate)
+		items = append(items, item)
+	}
+
+	return
+}
+
+// projectBudget is the time used by a project compared to its estimate
+type projectBudget struct {
+	Used     float64
+	Estimate int
+}
+
+func (b projectBudget) Percent() float64 {
+	return 100 * b.Used / float64(b.Estimate)
+}
+
+// IsOverThreshold returns true if a budget has passed the warning threshold
+func (b projectBudget) IsOverThreshold() bool {
+	return b.Percent() >= float64(getBudgetWarning())
+}
+
+// String returns a budget summary like "32.5 / 40h (81%)"
+func (b projectBudget) String() string {
+	used := strconv.FormatFloat(math.Round(b.Used*10)/10, 'f', -1, 64)
+	return fmt.Sprintf("%s / %dh (%.0f%%)", used, b.Estimate, b.Percent())
+}
+
+// getProjectBudget returns the budget for a project with an estimate. The
+// time used is Toggl's total for the project's stopped entries, if known, plus
+// the cached entries stopped since that total was downloaded and any running
+// entry. Without Toggl's total, all the cached entries are counted.
+func getProjectBudget(pid int) (budget projectBudget, ok bool) {
+	details, _, found := getProjectDetailsByID(pid)
+	if !found || details.EstimatedHours == nil || *details.EstimatedHours <= 0 {
+		return
+	}
+
+	var seconds int64
+	if details.ActualSeconds != nil {
+		seconds = *details.ActualSeconds
+	}
+
+	// Details cached before fetch times were recorded came from the last full
+	// refresh
+	fetched := details.Fetched
+	if fetched.IsZero() {
+		fetched = cache.Sync.Account
+	}
+
+	for _, entry := range findTimersByProjectID(pid) {
+		if entry.IsRunning() {
+			seconds += int64(time.Now().Sub(entry.StartTime()) / time.Second)
+		} else if details.ActualSeconds == nil || entry.StopTime().After(fetched) {
+			seconds += entry.Duration
+		}
+	}
+
+	return projectBudget{
+		Used:     float64(seconds) / 3600,
+		Estimate: *details.EstimatedHours,
+	}, true
+}
+
+// getBudgetWarning returns the percentage of an estimate at which a project's
+// budget warning is shown
+func getBudgetWarning() int {
+	if config.BudgetWarning > 0 {
+		return config.BudgetWarning
+	}
+	return 90
+}
+
+// budgetWarning returns a warning if a project has passed its budget
+// threshold
+func budgetWarning(pid int) (warning string, ok bool) {
+	budget, hasBudget := getProjectBudget(pid)
+	if !hasBudget || !budget.IsOverThreshold() {
+		return
+	}
+	project, _, _ := getProjectByID(pid)
+	return fmt.Sprintf(`Project "%s" has used %s`, project.Name, budget.String()), true
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jason0x43/go-alfred"
+	"github.com/jason0x43/go-toggl"
+)
+
+// Changes to time entries that are made while toggl.com can't be reached are
+// applied to the cache and queued. The queue is replayed in order on the next
+// successful refresh. Entries created while offline have negative IDs until
+// they're replayed.
+
+type queueOp string
+
+const (
+	queueCreate queueOp = "create"
+	queueUpdate queueOp = "update"
+	queueDelete queueOp = "delete"
+)
+
+// queuedOp is a change to a time entry. Base is the cached state of the entry
+// when the change was made; if the server's copy no longer matches it when the
+// change is replayed, the change is reported as a conflict.
+type queuedOp struct {
+	Op    queueOp          `json:"op"`
+	Entry toggl.TimeEntry  `json:"entry"`
+	Base  *toggl.TimeEntry `json:"base,omitempty"`
+	Time  time.Time        `json:"time"`
+}
+
+type offlineQueue struct {
+	Ops       []queuedOp `json:"ops"`
+	Conflicts []string   `json:"conflicts,omitempty"`
+}
+
+var queue offlineQueue
+
+// isOfflineError returns true if an error indicates that toggl.com couldn't be
+// reached, as opposed to rejecting a request
+func isOfflineError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "Error making request")
+}
+
+// isQueueing returns true if changes should be queued rather than sent to
+// toggl.com, which is the case while earlier changes are waiting to be synced
+func isQueueing() bool {
+	return len(queue.Ops) > 0
+}
+
+// isQueued returns true if a time entry has changes waiting to be synced
+func isQueued(id int) bool {
+	for _, op := range queue.Ops {
+		if op.Entry.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// queueNewEntry adds a new time entry to the cache and the queue. A running
+// entry stops any other running entry, as it would on toggl.com.
+func queueNewEntry(entry toggl.TimeEntry) toggl.TimeEntry {
+	// toggl.com keeps times to the second
+	if entry.Start != nil {
+		start := entry.Start.Truncate(time.Second)
+		entry.Start = &start
+	}
+
+	updateQueue(func() {
+		entry.ID = nextTempID()
+
+		if entry.IsRunning() {
+			if running, isRunning := getRunningTimer(); isRunning {
+				stopped := running.Copy()
+				stopped.Stop = entry.Start
+				stopped.Duration = int64(entry.StartTime().Sub(running.StartTime()) / time.Second)
+				addEntryUpdate(stopped)
+			}
+		}
+
+		cache.Account.TimeEntries = append(cache.Account.TimeEntries, entry)
+		queue.Ops = append(queue.Ops, queuedOp{Op: queueCreate, Entry: entry, Time: time.Now()})
+	})
+
+	return entry
+}
+
+// queueToggle stops a running time entry or starts a new copy of a stopped one.
+// A duration-only toggle of an entry from today restarts the entry itself, as
+// toggl.com would.
+func queueToggle(entry toggl.TimeEntry, durationOnly bool) toggl.TimeEntry {
+	// toggl.com keeps times to the second
+	now := time.Now().Truncate(time.Second)
+
+	if entry.IsRunning() {
+		stopped := entry.Copy()
+		stopped.Stop = &now
+		stopped.Duration = int64(now.Sub(entry.StartTime()) / time.Second)
+		return queueEntryUpdate(stopped)
+	}
+
+	started := entry.Copy()
+	started.ID = 0
+	started.Stop = nil
+	started.Duration = -1
+
+	if durationOnly && entry.StartTime().Local().Format("2006-01-02") == now.Format("2006-01-02") {
+		started = queueNewEntry(started)
+		queueEntryDelete(entry)
+		return started
+	}
+
+	started.SetStartTime(now, false)
+	return queueNewEntry(started)
+}
+
+// queueEntryUpdate applies an update to a cached time entry and queues it
+func queueEntryUpdate(entry toggl.TimeEntry) toggl.TimeEntry {
+	updateQueue(func() {
+		addEntryUpdate(entry)
+	})
+	return entry
+}
+
+// addEntryUpdate applies an update to a cached time entry and adds it to the
+// loaded queue
+func addEntryUpdate(entry toggl.TimeEntry) {
+	original, index, ok := getTimerByID(entry.ID)
+	if ok {
+		cache.Account.TimeEntries[index] = entry
+	}
+
+	if entry.ID < 0 {
+		// The entry hasn't been created yet, so just update its creation
+		for i := range queue.Ops {
+			if queue.Ops[i].Op == queueCreate && queue.Ops[i].Entry.ID == entry.ID {
+				queue.Ops[i].Entry = entry
+			}
+		}
+	} else {
+		op := queuedOp{Op: queueUpdate, Entry: entry, Time: time.Now()}
+		if ok {
+			op.Base = &original
+		}
+		queue.Ops = append(queue.Ops, op)
+	}
+}
+
+// queueEntryDelete removes a time entry from the cache and queues its deletion
+func queueEntryDelete(entry toggl.TimeEntry) {
+	updateQueue(func() {
+		if _, index, ok := getTimerByID(entry.ID); ok {
+			adata := &cache.Account
+			adata.TimeEntries = append(adata.TimeEntries[:index], adata.TimeEntries[index+1:]...)
+		}
+
+		if entry.ID < 0 {
+			// The entry was never created, so forget about it
+			var ops []queuedOp
+			for _, op := range queue.Ops {
+				if op.Entry.ID != entry.ID {
+					ops = append(ops, op)
+				}
+			}
+			queue.Ops = ops
+		} else {
+			base := entry
+			queue.Ops = append(queue.Ops, queuedOp{Op: queueDelete, Entry: entry, Base: &base,
+				Time: time.Now()})
+		}
+	})
+}
+
+// queueItems returns items describing changes waiting to be synced and any
+// conflicts from earlier syncs
+func queueItems() (items []alfred.Item) {
+	if len(queue.Ops) > 0 {
+		title := "1 change waiting to be synced"
+		if len(queue.Ops) > 1 {
+			title = fmt.Sprintf("%d changes waiting to be synced", len(queue.Ops))
+		}
+		items = append(items, alfred.Item{
+			Title:    title,
+			Subtitle: "Queued changes are sent to toggl.com on the next refresh",
+		})
+	}
+
+	for _, conflict := range queue.Conflicts {
+		items = append(items, alfred.Item{
+			Title:    "Sync conflict",
+			Subtitle: conflict,
+			Arg: &alfred.ItemArg{
+				Keyword: "status",
+				Mode:    alfred.ModeDo,
+				Data:    "dismissConflicts",
+			},
+		})
+	}
+
+	return
+}
+
+// replayQueue sends queued changes to toggl.com in order. The given account
+// data is updated as changes are made so that later changes to the same entry
+// can be checked for conflicts. Replaying stops if toggl.com can't be reached;
+// any remaining changes stay queued.
+//
+// The queue is locked while it's replayed so that changes queued by other
+// processes in the meantime aren't lost or replayed twice.
+func replayQueue(account *toggl.Account) {
+	unlock, err := lockFile(queueFile)
+	if err != nil {
+		dlog.Printf("Error replaying queue: %v", err)
+		return
+	}
+	defer unlock()
+	loadQueue()
+
+	session := toggl.OpenSession(config.APIKey)
+	idMap := map[int]int{}
+
+	findEntry := func(id int) (int, bool) {
+		for i, entry := range account.TimeEntries {
+			if entry.ID == id {
+				return i, true
+			}
+		}
+		return -1, false
+	}
+
+	for len(queue.Ops) > 0 {
+		op := queue.Ops[0]
+		entry := op.Entry.Copy()
+
+		if newID, ok := idMap[entry.ID]; ok {
+			entry.ID = newID
+		}
+
+		var conflict string
+		var err error
+
+		if op.Op != queueCreate && op.Base != nil {
+			if index, ok := findEntry(entry.ID); !ok {
+				conflict = "it was deleted on toggl.com"
+			} else if !isSameEntryState(account.TimeEntries[index], *op.Base) {
+				conflict = "it was changed on toggl.com"
+			}
+		}
+
+		if conflict == "" {
+			switch op.Op {
+			case queueCreate:
+				entry.ID = 0
+				var created toggl.TimeEntry
+				if created, err = createTimeEntry(entry); err == nil {
+					idMap[op.Entry.ID] = created.ID
+					account.TimeEntries = append(account.TimeEntries, created)
+				}
+
+			case queueUpdate:
+				var updated toggl.TimeEntry
+				if updated, err = putTimeEntry(entry); err == nil {
+					if index, ok := findEntry(updated.ID); ok {
+						account.TimeEntries[index] = updated
+					}
+				}
+
+			case queueDelete:
+				if _, err = session.DeleteTimeEntry(entry); err == nil {
+					if index, ok := findEntry(entry.ID); ok {
+						account.TimeEntries = append(account.TimeEntries[:index],
+							account.TimeEntries[index+1:]...)
+					}
+				}
+			}
+
+			if isOfflineError(err) {
+				dlog.Printf("Still offline, keeping %d queued changes", len(queue.Ops))
+				break
+			}
+
+			if err != nil {
+				conflict = err.Error()
+			}
+		}
+
+		if conflict != "" {
+			message := fmt.Sprintf(`Couldn't %s "%s" from %s: %s`, op.Op, op.Entry.Description,
+				op.Time.Local().Format("Jan 2 15:04"), conflict)
+			dlog.Println(message)
+			queue.Conflicts = append(queue.Conflicts, message)
+		}
+
+		queue.Ops = queue.Ops[1:]
+	}
+
+	// Entries created while replaying have real IDs now
+	for i := range queue.Ops {
+		if newID, ok := idMap[queue.Ops[i].Entry.ID]; ok {
+			queue.Ops[i].Entry.ID = newID
+		}
+	}
+
+	if err := writeJSON(queueFile, &queue); err != nil {
+		dlog.Printf("Error saving queue: %v\n", err)
+	}
+}
+
+// applyQueue applies changes that are still queued to freshly downloaded
+// account data so that they continue to be shown
+func applyQueue(account *toggl.Account) {
+	for _, op := range queue.Ops {
+		index := -1
+		for i, entry := range account.TimeEntries {
+			if entry.ID == op.Entry.ID {
+				index = i
+				break
+			}
+		}
+
+		switch op.Op {
+		case queueCreate:
+			account.TimeEntries = append(account.TimeEntries, op.Entry)
+		case queueUpdate:
+			if index != -1 {
+				account.TimeEntries[index] = op.Entry
+			}
+		case queueDelete:
+			if index != -1 {
+				account.TimeEntries = append(account.TimeEntries[:index],
+					account.TimeEntries[index+1:]...)
+			}
+		}
+	}
+}
+
+// isSameEntryState returns true if two versions of a time entry have the same
+// user-visible values. Times are compared to the second, which is all that
+// toggl.com keeps.
+func isSameEntryState(a, b toggl.TimeEntry) bool {
+	sameTime := func(x, y time.Time) bool {
+		return x.Truncate(time.Second).Equal(y.Truncate(time.Second))
+	}
+	sameID := func(x, y *int) bool {
+		return (x == nil) == (y == nil) && (x == nil || *x == *y)
+	}
+
+	if a.Description != b.Description || a.Billable != b.Billable ||
+		a.IsRunning() != b.IsRunning() || !sameTime(a.StartTime(), b.StartTime()) {
+		return false
+	}
+
+	if !sameID(a.Pid, b.Pid) || !sameID(a.Tid, b.Tid) {
+		return false
+	}
+
+	if !a.IsRunning() && !sameTime(a.StopTime(), b.StopTime()) {
+		return false
+	}
+
+	if len(a.Tags) != len(b.Tags) {
+		return false
+	}
+	for _, tag := range a.Tags {
+		if !b.HasTag(tag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nextTempID returns an unused negative ID for an entry created offline
+func nextTempID() int {
+	id := 0
+	for _, entry := range cache.Account.TimeEntries {
+		if entry.ID < id {
+			id = entry.ID
+		}
+	}
+	for _, op := range queue.Ops {
+		if op.Entry.ID < id {
+			id = op.Entry.ID
+		}
+	}
+	return id - 1
+}
+
+// loadQueue loads the queue file, replacing the loaded queue
+func loadQueue() {
+	resetValue(&queue)
+	if err := alfred.LoadJSON(queueFile, &queue); err != nil && !os.IsNotExist(err) {
+		dlog.Println("Error loading queue:", err)
+	}
+}
+
+// updateQueue reloads the queue, applies a change to it, and saves it, all
+// under a lock so that changes queued by other processes aren't lost. The
+// cache is saved afterwards since queued changes are applied to it.
+func updateQueue(change func()) {
+	if err := updateJSON(queueFile, &queue, change); err != nil {
+		dlog.Printf("Error saving queue: %v\n", err)
+	}
+	if err := saveCache(); err != nil {
+		dlog.Printf("Error saving cache: %v\n", err)
+	}
+}
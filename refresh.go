@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jason0x43/go-alfred"
+)
+
+// With background refreshes enabled, filters show cached data right away. Stale
+// data is refreshed by a detached copy of the workflow, which asks Alfred to
+// rerun the query once the fresh data has been saved. Only top-level queries
+// are rerun, and only if the refresh finishes soon after the query was typed,
+// so that Alfred isn't reopened after the user has moved on.
+
+// backgroundRefreshArg starts the workflow as a background refresh process
+// rather than as an Alfred command
+const backgroundRefreshArg = "-background-refresh"
+
+// rerunWindow is how long after a query a background refresh may rerun it
+const rerunWindow = 5 * time.Second
+
+func getRefreshInterval() int {
+	if config.RefreshInterval > 0 {
+		return config.RefreshInterval
+	}
+	return 5
+}
+
+// isStale returns true if the cached data is older than the refresh interval
+func isStale() bool {
+	return time.Now().Sub(cache.Time) >= time.Duration(getRefreshInterval())*time.Minute
+}
+
+// startBackgroundRefresh starts a detached process to refresh the cache. The
+// process reruns the query that started this one if it can be rerun.
+func startBackgroundRefresh() {
+	exe, err := os.Executable()
+	if err != nil {
+		dlog.Printf("Error finding executable: %v", err)
+		return
+	}
+
+	args := []string{backgroundRefreshArg}
+	if query, ok := getRerunQuery(os.Args[1:]); ok {
+		args = append(args, query)
+	}
+
+	cmd := exec.Command(exe, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		dlog.Printf("Error starting background refresh: %v", err)
+		return
+	}
+
+	dlog.Printf("Started background refresh in process %d", cmd.Process.Pid)
+	cmd.Process.Release()
+}
+
+// backgroundRefresh refreshes the cache and, if anything changed, reruns the
+// given query in Alfred. The query isn't rerun if the refresh took longer than
+// rerunWindow. Only one background refresh runs at a time.
+func backgroundRefresh(args []string) {
+	// The process is started as soon as the query is typed
+	started := time.Now()
+
+	unlock, ok, err := tryLockFile(cacheFile + ".refresh")
+	if err != nil {
+		dlog.Println(err)
+		return
+	}
+	if !ok {
+		dlog.Println("A background refresh is already running")
+		return
+	}
+	defer unlock()
+
+	// Another process may have refreshed the cache or queued changes since
+	// this one started. Queued changes are replayed under the queue's lock, so
+	// changes queued by a foreground process while this one is replaying them
+	// wait for it rather than being lost or sent twice.
+	if err := loadCache(); err != nil {
+		dlog.Println("Error loading cache:", err)
+	}
+	loadQueue()
+	if !isStale() && !isQueueing() {
+		return
+	}
+
+	snapshot := func() interface{} {
+		return toGeneric([]interface{}{cache.Account, cache.ProjectDetails, cache.Tasks})
+	}
+
+	before := snapshot()
+	if err := refresh(); err != nil {
+		dlog.Println("Error refreshing cache:", err)
+		return
+	}
+	if reflect.DeepEqual(before, snapshot()) {
+		dlog.Println("Nothing changed in background refresh")
+		return
+	}
+
+	if len(args) == 0 {
+		return
+	}
+	if time.Now().Sub(started) > rerunWindow {
+		dlog.Println("Background refresh took too long to rerun the query")
+		return
+	}
+	rerun(args[0])
+}
+
+// getRerunQuery returns an Alfred query for the main keyword that shows the
+// same list as a filter invoked with the given arguments. ok is false for a
+// submenu, such as an entry's property list, since the external trigger only
+// accepts a query and can't pass along the item data that selected it.
+func getRerunQuery(args []string) (query string, ok bool) {
+	if len(args) == 0 {
+		return "", true
+	}
+
+	query = args[0]
+	if len(args) > 1 && args[1] != "" {
+		var data struct {
+			Keyword string `json:"keyword"`
+			Data    string `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(args[1]), &data); err != nil || data.Data != "" {
+			return "", false
+		}
+		if data.Keyword != "" {
+			query = data.Keyword + " " + query
+		}
+	}
+
+	return query, true
+}
+
+// rerun runs a query through the workflow's external trigger
+func rerun(query string) {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	script := fmt.Sprintf(
+		`tell application id "com.runningwithcrayons.Alfred" to run trigger "start" in workflow "%s" with argument "%s"`,
+		escape.Replace(workflow.BundleID()), escape.Replace(query))
+	if _, err := alfred.RunScript(script); err != nil {
+		dlog.Printf("Error rerunning query: %v", err)
+	}
+}
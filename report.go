@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/jason0x43/go-alfred"
+	"github.com/jason0x43/go-toggl"
 )
 
 // ReportFilter is a command
@@ -36,6 +38,10 @@ func (c ReportFilter) Items(arg, data string) (items []alfred.Item, err error) {
 		}
 	}
 
+	if cfg.Export != nil && cfg.Span != nil {
+		return exportMenuItems(cfg, arg), nil
+	}
+
 	var span span
 	if cfg.Span != nil {
 		span = *cfg.Span
@@ -45,20 +51,29 @@ func (c ReportFilter) Items(arg, data string) (items []alfred.Item, err error) {
 			}
 		}
 	} else {
-		var spanArg string
-		spanArg, arg = alfred.SplitCmd(arg)
-
-		for _, value := range []string{"today", "yesterday", "week"} {
-			if alfred.FuzzyMatches(value, spanArg) {
-				span, _ := getSpan(value)
-				items = append(items, createReportMenuItem(span))
+		spanArg, groupArg, hasGrouping := splitGroupingArg(arg)
+		groupings, partial := parseGroupings(groupArg)
+
+		if hasGrouping && (partial != "" || len(groupings) == 0) {
+			// Suggest groupings for a partly typed name
+			prefix := strings.TrimSuffix(arg, partial)
+			if !strings.HasSuffix(prefix, " ") {
+				prefix += " "
 			}
+			for _, grouping := range groupingNames {
+				if alfred.FuzzyMatches(string(grouping), partial) {
+					items = append(items, alfred.Item{
+						Title:        "by " + string(grouping),
+						Subtitle:     "Group the report by " + string(grouping),
+						Autocomplete: prefix + string(grouping) + " ",
+					})
+				}
+			}
+			return
 		}
 
-		if matched, _ := regexp.MatchString(`^\d`, spanArg); matched {
-			if span, err = getSpan(spanArg); err == nil {
-				items = append(items, createReportMenuItem(span))
-			}
+		for _, span := range matchSpans(spanArg) {
+			items = append(items, createReportMenuItem(span, groupings))
 		}
 
 		if len(items) == 0 {
@@ -93,21 +108,52 @@ func (c ReportFilter) Items(arg, data string) (items []alfred.Item, err error) {
 	return items, nil
 }
 
+// Do runs the command
+func (c ReportFilter) Do(data string) (out string, err error) {
+	var cfg reportCfg
+	if err = json.Unmarshal([]byte(data), &cfg); err != nil {
+		return
+	}
+
+	if cfg.Export != nil && cfg.Span != nil {
+		return exportReport(cfg)
+	}
+
+	return "", fmt.Errorf("Unrecognized input: %s", data)
+}
+
 // support -------------------------------------------------------------------
 
 type reportGrouping string
 
 const (
-	groupByDay     reportGrouping = "day"
-	groupByProject reportGrouping = "project"
+	groupByDay         reportGrouping = "day"
+	groupByProject     reportGrouping = "project"
+	groupByTask        reportGrouping = "task"
+	groupByTag         reportGrouping = "tag"
+	groupByClient      reportGrouping = "client"
+	groupByDescription reportGrouping = "description"
 )
 
+// groupingNames are the groupings that can be chosen in the report menu
+var groupingNames = []reportGrouping{
+	groupByDay,
+	groupByProject,
+	groupByClient,
+	groupByTag,
+	groupByDescription,
+}
+
 type reportCfg struct {
-	Project    *int            `json:"project,omitempty"`
-	EntryTitle *string         `json:"entrytitle,omitempty"`
-	Span       *span           `json:"span,omitempty"`
-	Grouping   *reportGrouping `json:"grouping,omitempty"`
-	Previous   *reportCfg      `json:"previous,omitempty"`
+	Project    *int             `json:"project,omitempty"`
+	EntryTitle *string          `json:"entrytitle,omitempty"`
+	Tag        *string          `json:"tag,omitempty"`
+	Client     *int             `json:"client,omitempty"`
+	Span       *span            `json:"span,omitempty"`
+	Grouping   *reportGrouping  `json:"grouping,omitempty"`
+	Then       []reportGrouping `json:"then,omitempty"`
+	Previous   *reportCfg       `json:"previous,omitempty"`
+	Export     *reportExport    `json:"export,omitempty"`
 }
 
 type span struct {
@@ -121,6 +167,7 @@ type span struct {
 type dateEntry struct {
 	total   int64
 	name    string
+	date    time.Time
 	entries map[string]*timeEntry
 }
 
@@ -129,7 +176,9 @@ type projectEntry struct {
 	name    string
 	id      int
 	running bool
+	billing billingTotals
 	entries map[string]*timeEntry
+	tasks   map[string]*timeEntry
 }
 
 type timeEntry struct {
@@ -138,13 +187,29 @@ type timeEntry struct {
 	description string
 }
 
+// groupEntry is the time for one tag, client or description. value is the
+// tag or description, and id is the client ID.
+type groupEntry struct {
+	total   int64
+	name    string
+	value   string
+	id      int
+	running bool
+	billing billingTotals
+}
+
 type summaryReport struct {
-	total    int64
-	projects map[string]*projectEntry
-	dates    map[string]*dateEntry
+	total        int64
+	complete     bool
+	billing      billingTotals
+	projects     map[string]*projectEntry
+	dates        map[string]*dateEntry
+	tags         map[string]*groupEntry
+	clients      map[string]*groupEntry
+	descriptions map[string]*groupEntry
 }
 
-func createReportMenuItem(s span) (item alfred.Item) {
+func createReportMenuItem(s span, groupings []reportGrouping) (item alfred.Item) {
 	cfg := reportCfg{Span: &s}
 
 	subtitle := "Generate a report for "
@@ -154,6 +219,17 @@ func createReportMenuItem(s span) (item alfred.Item) {
 		subtitle += s.Name
 	}
 
+	if len(groupings) > 0 {
+		cfg.Grouping = &groupings[0]
+		cfg.Then = groupings[1:]
+
+		var names []string
+		for _, grouping := range groupings {
+			names = append(names, string(grouping))
+		}
+		subtitle += ", grouped by " + strings.Join(names, " › ")
+	}
+
 	item = alfred.Item{
 		Autocomplete: s.Name,
 		Title:        s.Name,
@@ -164,7 +240,7 @@ func createReportMenuItem(s span) (item alfred.Item) {
 		},
 	}
 
-	if s.MultiDay {
+	if s.MultiDay && len(groupings) == 0 {
 		grouping := groupByDay
 		cfg.Grouping = &grouping
 		item.AddMod(alfred.ModAlt, alfred.ItemMod{
@@ -189,24 +265,38 @@ func createReportItems(
 		projectID = *cfg.Project
 	}
 
-	entryTitle := ""
-	if cfg.EntryTitle != nil {
-		entryTitle = *cfg.EntryTitle
-	}
-
 	var grouping reportGrouping
 	if cfg.Grouping != nil {
 		grouping = *cfg.Grouping
 	}
 
 	var report *summaryReport
-	if report, err = generateReport(span.Start, span.End, projectID, entryTitle); err != nil {
+	if report, err = generateReport(span.Start, span.End, cfg); err != nil {
+		return
+	}
+
+	if !report.complete && len(report.projects) == 0 {
+		items = append(items, alfred.Item{
+			Title:    "Couldn't download time entries for " + span.Name,
+			Subtitle: "Older time entries are downloaded from toggl.com as they're needed",
+		})
 		return
 	}
 
 	dlog.Printf("creating report with data %#v", data)
 
-	newCfg := reportCfg{Span: &span, Previous: cfg}
+	// Filters apply at every level below the one that set them, and the next
+	// level uses the next of any chosen groupings
+	newCfg := *cfg
+	newCfg.Span = &span
+	newCfg.Previous = cfg
+	newCfg.Grouping = nil
+	newCfg.Then = nil
+	newCfg.Export = nil
+	if len(cfg.Then) > 0 {
+		newCfg.Grouping = &cfg.Then[0]
+		newCfg.Then = cfg.Then[1:]
+	}
 
 	var total int64
 	var totalName string
@@ -221,15 +311,17 @@ func createReportItems(
 
 		dlog.Printf("checking %d dates", len(report.dates))
 		for _, date := range report.dates {
-			totalName = "for " + spanName
-			if entryTitle != "" {
-				totalName += " for " + entryTitle
-			}
+			totalName = "for " + spanName + describeReportFilters(cfg)
 
 			dateName := date.name
 
 			if alfred.FuzzyMatches(dateName, arg) {
-				if span, e := getSpan(date.name); e == nil {
+				// Days from another year need the year to find their span
+				daySpan := date.name
+				if date.date.Year() != time.Now().Year() {
+					daySpan = date.date.Format("1/2/2006")
+				}
+				if span, e := getSpan(daySpan); e == nil {
 					newCfg.Span = &span
 				} else {
 					dlog.Printf("Error getting span for %s: %v", date.name, e)
@@ -247,6 +339,46 @@ func createReportItems(
 				total += date.total
 			}
 		}
+	} else if grouping == groupByTag || grouping == groupByClient ||
+		grouping == groupByDescription {
+		// By-tag, by-client or by-description report
+
+		totalName = "for " + spanName + describeReportFilters(cfg)
+		groups := report.getGroups(grouping)
+
+		dlog.Printf("checking %d %s groups", len(groups), grouping)
+
+		for _, group := range groups {
+			if !alfred.FuzzyMatches(group.name, arg) {
+				continue
+			}
+
+			groupCfg := newCfg
+			groupCfg.setFilter(grouping, group)
+			if groupCfg.Grouping == nil {
+				subgrouping := getSubgrouping(grouping)
+				groupCfg.Grouping = &subgrouping
+			}
+
+			item := alfred.Item{
+				Title:    group.name,
+				Subtitle: report.formatTotal(group.total, group.billing),
+				Arg: &alfred.ItemArg{
+					Keyword: "report",
+					Data:    alfred.Stringify(&groupCfg),
+				},
+			}
+
+			if group.running {
+				item.Icon = "running.png"
+			}
+
+			items = append(items, item)
+		}
+
+		// An entry with several tags is in each of their groups, so the
+		// groups may add up to more than the report's total
+		total = report.total
 	} else {
 		// By-project report
 
@@ -258,7 +390,28 @@ func createReportItems(
 
 				dlog.Printf("have projectID: %d", projectID)
 
-				totalName = fmt.Sprintf("for %s for %s", spanName, project.name)
+				totalName = "for " + spanName + describeReportFilters(cfg)
+
+				if grouping == groupByTask {
+					// Tasks aren't broken down any further
+					for name, task := range project.tasks {
+						if alfred.FuzzyMatches(name, arg) {
+							item := alfred.Item{
+								Title:    name,
+								Subtitle: formatDuration(task.total),
+							}
+
+							if task.running {
+								item.Icon = "running.png"
+							}
+
+							items = append(items, item)
+						}
+
+						total += task.total
+					}
+					continue
+				}
 
 				grouping := groupByDay
 				newCfg.Grouping = &grouping
@@ -290,10 +443,7 @@ func createReportItems(
 			} else {
 				// By-project report for all projects
 
-				totalName = "for " + spanName
-				if entryTitle != "" {
-					totalName += " for " + entryTitle
-				}
+				totalName = "for " + spanName + describeReportFilters(cfg)
 
 				projectName := project.name
 
@@ -303,7 +453,7 @@ func createReportItems(
 				if alfred.FuzzyMatches(projectName, arg) {
 					item := alfred.Item{
 						Title:    projectName,
-						Subtitle: formatDuration(project.total),
+						Subtitle: report.formatTotal(project.total, project.billing),
 						Arg: &alfred.ItemArg{
 							Keyword: "report",
 							Data:    alfred.Stringify(&newCfg),
@@ -314,6 +464,19 @@ func createReportItems(
 						item.Icon = "running.png"
 					}
 
+					if len(findTasksByProjectID(project.id)) > 0 {
+						taskCfg := newCfg
+						taskGrouping := groupByTask
+						taskCfg.Grouping = &taskGrouping
+						item.AddMod(alfred.ModAlt, alfred.ItemMod{
+							Subtitle: "Break this project down by task",
+							Arg: &alfred.ItemArg{
+								Keyword: "report",
+								Data:    alfred.Stringify(&taskCfg),
+							},
+						})
+					}
+
 					items = append(items, item)
 					total += project.total
 				}
@@ -331,6 +494,15 @@ func createReportItems(
 			Subtitle: alfred.Line,
 		}
 
+		if report.hasBillableTime() {
+			item.Subtitle = report.billing.String()
+		}
+
+		if !report.complete {
+			item.Title += " (incomplete)"
+			item.Subtitle = "Some time entries couldn't be downloaded from toggl.com"
+		}
+
 		if newCfg.EntryTitle != nil {
 			newCfg.EntryTitle = nil
 		} else if newCfg.Project != nil {
@@ -346,13 +518,187 @@ func createReportItems(
 			}
 		}
 
+		exportCfg := *cfg
+		exportCfg.Export = &reportExport{}
+		item.AddMod(alfred.ModCmd, alfred.ItemMod{
+			Subtitle: "Export this report...",
+			Arg: &alfred.ItemArg{
+				Keyword: "report",
+				Data:    alfred.Stringify(&exportCfg),
+			},
+		})
+
 		items = alfred.InsertItem(items, item, 0)
 	}
 
 	return
 }
 
-// expand fills in the start and end times for a span
+// getGroups returns the groups for a tag, client or description grouping
+func (r *summaryReport) getGroups(grouping reportGrouping) map[string]*groupEntry {
+	switch grouping {
+	case groupByTag:
+		return r.tags
+	case groupByClient:
+		return r.clients
+	default:
+		return r.descriptions
+	}
+}
+
+// setFilter limits a report to the entries in a group
+func (c *reportCfg) setFilter(grouping reportGrouping, group *groupEntry) {
+	switch grouping {
+	case groupByTag:
+		tag := group.value
+		c.Tag = &tag
+	case groupByClient:
+		id := group.id
+		c.Client = &id
+	default:
+		title := group.value
+		c.EntryTitle = &title
+	}
+}
+
+// getSubgrouping returns the grouping for the level below a group when no
+// other grouping was chosen
+func getSubgrouping(grouping reportGrouping) reportGrouping {
+	if grouping == groupByDescription {
+		return groupByDay
+	}
+	return groupByProject
+}
+
+// describeReportFilters returns a description of a report's filters, like
+// " for Acme for #meeting"
+func describeReportFilters(cfg *reportCfg) (desc string) {
+	if cfg.Client != nil {
+		name := "<No client>"
+		if client, _, ok := getClientByID(*cfg.Client); ok {
+			name = client.Name
+		}
+		desc += " for " + name
+	}
+
+	if cfg.Project != nil {
+		name := "<No project>"
+		if project, _, ok := getProjectByID(*cfg.Project); ok {
+			name = project.Name
+		}
+		desc += " for " + name
+	}
+
+	if cfg.Tag != nil {
+		name := "<No tag>"
+		if *cfg.Tag != "" {
+			name = "#" + *cfg.Tag
+		}
+		desc += " for " + name
+	}
+
+	if cfg.EntryTitle != nil {
+		name := "<No description>"
+		if *cfg.EntryTitle != "" {
+			name = *cfg.EntryTitle
+		}
+		desc += " for " + name
+	}
+
+	return
+}
+
+// groupingArgPattern splits a report menu argument like "week by client
+// project" into a span and groupings
+var groupingArgPattern = regexp.MustCompile(`^(.*?)\s+by\b\s*(.*)$`)
+
+func splitGroupingArg(arg string) (spanArg, groupArg string, hasGrouping bool) {
+	if match := groupingArgPattern.FindStringSubmatch(arg); match != nil {
+		return match[1], match[2], true
+	}
+	return arg, "", false
+}
+
+// parseGroupings parses a list of grouping names like "client project" or
+// "client>project". partial is the last name if it isn't a known grouping yet.
+func parseGroupings(arg string) (groupings []reportGrouping, partial string) {
+	names := regexp.MustCompile(`[\s>/,]+`).Split(strings.TrimSpace(arg), -1)
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+
+		found := false
+		for _, grouping := range groupingNames {
+			if strings.ToLower(name) == string(grouping) {
+				groupings = append(groupings, grouping)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			if i == len(names)-1 && !strings.HasSuffix(arg, " ") {
+				partial = name
+			} else {
+				dlog.Printf("Ignoring unknown grouping '%s'", name)
+			}
+		}
+	}
+	return
+}
+
+// spanNames are the named spans offered in span menus, other than weekdays
+var spanNames = []string{
+	"today",
+	"yesterday",
+	"week",
+	"lastweek",
+	"month",
+	"lastmonth",
+	"quarter",
+	"year",
+	"last 7 days",
+	"last 30 days",
+}
+
+// lastDaysPattern matches spans like "last 10 days" or "last10"
+var lastDaysPattern = regexp.MustCompile(`^last\s*(\d+)(\s*days?)?$`)
+
+// matchSpans returns the spans that a span menu argument could refer to
+func matchSpans(arg string) (spans []span) {
+	if lastDaysPattern.MatchString(arg) {
+		if span, err := getSpan(arg); err == nil {
+			spans = append(spans, span)
+		}
+		return
+	}
+
+	spanArg, _ := alfred.SplitCmd(arg)
+
+	names := append([]string{}, spanNames...)
+	for i := 0; i < 7; i++ {
+		day := time.Weekday((cache.Account.BeginningOfWeek + i) % 7)
+		names = append(names, strings.ToLower(day.String()))
+	}
+
+	for _, name := range names {
+		if alfred.FuzzyMatches(name, spanArg) {
+			span, _ := getSpan(name)
+			spans = append(spans, span)
+		}
+	}
+
+	if matched, _ := regexp.MatchString(`^\d`, spanArg); matched {
+		if span, err := getSpan(spanArg); err == nil {
+			spans = append(spans, span)
+		}
+	}
+
+	return
+}
+
+// getSpan returns the span for a name or date with its start and end times
 func getSpan(arg string) (s span, err error) {
 	if arg == "today" {
 		s.Name = arg
@@ -365,18 +711,66 @@ func getSpan(arg string) (s span, err error) {
 	} else if arg == "week" {
 		s.Name = "week"
 		s.Label = "this week"
-		start := time.Now()
-		startOfWeek := cache.Account.BeginningOfWeek
-		startDay := int(start.Weekday())
-		delta := startDay - startOfWeek
-		if startDay < startOfWeek {
-			delta += 7
-		}
-		s.Start = toDayStart(start.AddDate(0, 0, -delta))
+		s.Start = getWeekStart(time.Now())
 		s.End = toDayEnd(time.Now())
-		dlog.Printf("Creating week span; weekStart=%d, today=%d, delta=%d, start=%v, end=%v",
-			startOfWeek, start.Weekday(), delta, s.Start, s.End)
+		dlog.Printf("Creating week span; weekStart=%d, today=%d, start=%v, end=%v",
+			cache.Account.BeginningOfWeek, time.Now().Weekday(), s.Start, s.End)
+		s.MultiDay = true
+	} else if arg == "lastweek" {
+		s.Name = arg
+		s.Label = "last week"
+		s.Start = getWeekStart(time.Now()).AddDate(0, 0, -7)
+		s.End = toDayEnd(s.Start.AddDate(0, 0, 6))
+		s.MultiDay = true
+	} else if arg == "month" {
+		s.Name = arg
+		s.Label = "this month"
+		now := time.Now()
+		s.Start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+		s.End = toDayEnd(now)
+		s.MultiDay = true
+	} else if arg == "lastmonth" {
+		s.Name = arg
+		s.Label = "last month"
+		now := time.Now()
+		s.Start = time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.Local)
+		s.End = toDayEnd(s.Start.AddDate(0, 1, -1))
+		s.MultiDay = true
+	} else if arg == "quarter" {
+		s.Name = arg
+		s.Label = "this quarter"
+		now := time.Now()
+		month := now.Month() - (now.Month()-1)%3
+		s.Start = time.Date(now.Year(), month, 1, 0, 0, 0, 0, time.Local)
+		s.End = toDayEnd(now)
+		s.MultiDay = true
+	} else if arg == "year" {
+		s.Name = arg
+		s.Label = "this year"
+		now := time.Now()
+		s.Start = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.Local)
+		s.End = toDayEnd(now)
 		s.MultiDay = true
+	} else if weekday, ok := getWeekday(arg); ok {
+		// The most recent occurrence of the day, which may be today
+		now := time.Now()
+		delta := (int(now.Weekday()) - int(weekday) + 7) % 7
+		s.Name = arg
+		s.Start = toDayStart(now.AddDate(0, 0, -delta))
+		s.End = toDayEnd(s.Start)
+		s.Label = s.Start.Format("Monday, Jan 2")
+	} else if match := lastDaysPattern.FindStringSubmatch(arg); match != nil {
+		var days int
+		if days, err = strconv.Atoi(match[1]); err != nil || days < 1 {
+			return s, fmt.Errorf("Invalid number of days in '%s'", arg)
+		}
+		s.Name = fmt.Sprintf("last %d days", days)
+		if days == 1 {
+			s.Name = "last 1 day"
+		}
+		s.Start = toDayStart(time.Now().AddDate(0, 0, 1-days))
+		s.End = toDayEnd(time.Now())
+		s.MultiDay = days > 1
 	} else {
 		if strings.Contains(arg, "..") {
 			parts := alfred.CleanSplitN(arg, "..", 2)
@@ -415,32 +809,36 @@ func getSpan(arg string) (s span, err error) {
 	return
 }
 
-func generateReport(
-	since, until time.Time,
-	projectID int,
-	entryTitle string,
-) (*summaryReport, error) {
+func generateReport(since, until time.Time, cfg *reportCfg) (*summaryReport, error) {
+	projectID := -1
+	if cfg.Project != nil {
+		projectID = *cfg.Project
+	}
+
 	dlog.Printf("Generating report from %s to %s for %d", since, until, projectID)
 
 	report := summaryReport{
-		projects: map[string]*projectEntry{},
-		dates:    map[string]*dateEntry{},
+		projects:     map[string]*projectEntry{},
+		dates:        map[string]*dateEntry{},
+		tags:         map[string]*groupEntry{},
+		clients:      map[string]*groupEntry{},
+		descriptions: map[string]*groupEntry{},
 	}
 	projects := getProjectsByID()
 
-	for _, entry := range cache.Account.TimeEntries {
+	var entries []toggl.TimeEntry
+	entries, report.complete = getTimeEntries(since, until)
+
+	for _, entry := range entries {
 		start := entry.StartTime()
 
 		if !start.Before(since) && !until.Before(start) {
-			if projectID != -1 && entry.Pid != nil && *entry.Pid != projectID {
-				continue
-			}
-
-			if entryTitle != "" && entry.Description != entryTitle {
+			if !matchesReportFilters(entry, cfg, projects) {
 				continue
 			}
 
 			var projectName string
+			clientID := getEntryClientID(entry, projects)
 
 			if entry.Pid == nil {
 				projectName = "<No project>"
@@ -458,26 +856,30 @@ func generateReport(
 				report.projects[projectName] = &projectEntry{
 					name:    projectName,
 					id:      id,
-					entries: map[string]*timeEntry{}}
+					entries: map[string]*timeEntry{},
+					tasks:   map[string]*timeEntry{}}
 			}
 
-			date := start.Format("1/2")
+			// Dates are named without a year, but a span may include the same
+			// day in different years
+			date := start.Format("2006-01-02")
 			if _, ok := report.dates[date]; !ok {
 				report.dates[date] = &dateEntry{
-					name:    date,
+					name:    start.Format("1/2"),
+					date:    time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local),
 					entries: map[string]*timeEntry{}}
 			}
 
 			project := report.projects[projectName]
 			dateEntry := report.dates[date]
-			duration := entry.Duration
+			seconds := entry.Duration
 
-			if duration < 0 {
-				duration = round(time.Now().Sub(entry.StartTime()).Seconds())
+			if seconds < 0 {
+				seconds = round(time.Now().Sub(entry.StartTime()).Seconds())
 				project.running = true
 			}
 
-			duration = roundDuration(duration, false)
+			duration := roundDuration(seconds, false)
 
 			if _, ok := project.entries[entry.Description]; !ok {
 				project.entries[entry.Description] = &timeEntry{description: entry.Description}
@@ -488,6 +890,49 @@ func generateReport(
 			}
 
 			project.entries[entry.Description].total += duration
+
+			taskName := "<No task>"
+			if entry.Tid != nil {
+				if task, _, ok := getTaskByID(*entry.Tid); ok {
+					taskName = task.Name
+				}
+			}
+			if _, ok := project.tasks[taskName]; !ok {
+				project.tasks[taskName] = &timeEntry{description: taskName}
+			}
+			if entry.Duration < 0 {
+				project.tasks[taskName].running = true
+			}
+			project.tasks[taskName].total += duration
+
+			tags := entry.Tags
+			if len(tags) == 0 {
+				tags = []string{""}
+			}
+			for _, tag := range tags {
+				name := "<No tag>"
+				if tag != "" {
+					name = "#" + tag
+				}
+				report.addToGroup(report.tags, name, tag, 0, duration, seconds, entry)
+			}
+
+			clientName := "<No client>"
+			if client, _, ok := getClientByID(clientID); ok {
+				clientName = client.Name
+			}
+			report.addToGroup(report.clients, clientName, "", clientID, duration, seconds, entry)
+
+			descName := entry.Description
+			if descName == "" {
+				descName = "<No description>"
+			}
+			report.addToGroup(report.descriptions, descName, entry.Description, 0, duration, seconds,
+				entry)
+
+			project.billing.add(entry, duration, seconds)
+			report.billing.add(entry, duration, seconds)
+
 			dateEntry.total += duration
 			project.total += duration
 			report.total += duration
@@ -497,6 +942,90 @@ func generateReport(
 	return &report, nil
 }
 
+// addToGroup adds an entry's time to a tag, client or description group
+func (r *summaryReport) addToGroup(
+	groups map[string]*groupEntry,
+	name, value string,
+	id int,
+	duration, seconds int64,
+	entry toggl.TimeEntry,
+) {
+	group, ok := groups[name]
+	if !ok {
+		group = &groupEntry{name: name, value: value, id: id}
+		groups[name] = group
+	}
+	group.total += duration
+	group.billing.add(entry, duration, seconds)
+	if entry.Duration < 0 {
+		group.running = true
+	}
+}
+
+// hasBillableTime returns true if any of a report's time is billable
+func (r *summaryReport) hasBillableTime() bool {
+	return r.billing.billable > 0
+}
+
+// formatTotal formats the total time for a line of a report, adding the
+// billable and non-billable parts if the report has any billable time
+func (r *summaryReport) formatTotal(total int64, billing billingTotals) string {
+	if !r.hasBillableTime() {
+		return formatDuration(total)
+	}
+	return formatDuration(total) + " · " + billing.String()
+}
+
+// matchesReportFilters returns true if an entry passes a report's project,
+// description, tag and client filters
+func matchesReportFilters(entry toggl.TimeEntry, cfg *reportCfg, projects map[int]toggl.Project) bool {
+	if cfg.Project != nil {
+		// Entries without a project are in the report's project 0
+		pid := 0
+		if entry.Pid != nil {
+			pid = *entry.Pid
+		}
+		if pid != *cfg.Project {
+			return false
+		}
+	}
+	if cfg.EntryTitle != nil && entry.Description != *cfg.EntryTitle {
+		return false
+	}
+	if cfg.Tag != nil && !hasReportTag(entry, *cfg.Tag) {
+		return false
+	}
+	if cfg.Client != nil && getEntryClientID(entry, projects) != *cfg.Client {
+		return false
+	}
+	return true
+}
+
+// getEntryClientID returns the ID of the client of an entry's project, or 0 if
+// it doesn't have one
+func getEntryClientID(entry toggl.TimeEntry, projects map[int]toggl.Project) int {
+	if entry.Pid != nil {
+		if project, ok := projects[*entry.Pid]; ok && project.Cid != nil {
+			return *project.Cid
+		}
+	}
+	return 0
+}
+
+// hasReportTag returns true if an entry has a tag, or has no tags if the tag
+// is empty
+func hasReportTag(entry toggl.TimeEntry, tag string) bool {
+	if tag == "" {
+		return len(entry.Tags) == 0
+	}
+	for _, t := range entry.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
 var dateFormats = map[string]*regexp.Regexp{
 	"1/2":      regexp.MustCompile(`^\d\d?\/\d\d?$`),
 	"1/2/06":   regexp.MustCompile(`^\d\d?\/\d\d?\/\d\d$`),
@@ -520,6 +1049,28 @@ func toDayStart(date time.Time) time.Time {
 	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
 }
 
+// getWeekStart returns the start of the week containing a date, based on the
+// account's beginning of week
+func getWeekStart(date time.Time) time.Time {
+	startOfWeek := cache.Account.BeginningOfWeek
+	startDay := int(date.Weekday())
+	delta := startDay - startOfWeek
+	if startDay < startOfWeek {
+		delta += 7
+	}
+	return toDayStart(date.AddDate(0, 0, -delta))
+}
+
+// getWeekday returns the day of the week named by a span argument
+func getWeekday(arg string) (day time.Weekday, ok bool) {
+	for day = time.Sunday; day <= time.Saturday; day++ {
+		if strings.ToLower(day.String()) == arg {
+			return day, true
+		}
+	}
+	return
+}
+
 // return a datetime at the maximum time on the given date
 func toDayEnd(date time.Time) time.Time {
 	date = date.In(time.Local)
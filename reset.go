@@ -26,6 +26,15 @@ func (c ResetCommand) About() alfred.CommandDef {
 func (c ResetCommand) Do(data string) (string, error) {
 	err1 := os.Remove(configFile)
 	err2 := os.Remove(cacheFile)
+	os.Remove(undoFile)
+	os.Remove(queueFile)
+	os.Remove(historyFile)
+	os.Remove(configFile + ".lock")
+	os.Remove(cacheFile + ".lock")
+	os.Remove(cacheFile + ".refresh.lock")
+	os.Remove(queueFile + ".lock")
+	os.Remove(undoFile + ".lock")
+	os.Remove(historyFile + ".lock")
 
 	if err1 != nil || err2 != nil {
 		workflow.ShowMessage("One or more data files could not be removed")
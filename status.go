@@ -23,7 +23,13 @@ func (c StatusFilter) About() alfred.CommandDef {
 func (c StatusFilter) Items(arg, data string) (items []alfred.Item, err error) {
 	dlog.Printf("status items with arg=%s, data=%s", arg, data)
 
-	if err = refresh(); err != nil {
+	if err = refresh(); isOfflineError(err) {
+		items = append(items, alfred.Item{
+			Title:    "Can't reach toggl.com",
+			Subtitle: "Showing cached data; changes will be synced later",
+		})
+		err = nil
+	} else if err != nil {
 		items = append(items, alfred.Item{
 			Title:    "Error syncing with toggl.com",
 			Subtitle: fmt.Sprintf("%v", err),
@@ -31,6 +37,9 @@ func (c StatusFilter) Items(arg, data string) (items []alfred.Item, err error) {
 		return
 	}
 
+	items = append(items, noticeItems()...)
+	items = append(items, queueItems()...)
+
 	if entry, found := getRunningTimer(); found {
 		startTime := entry.StartTime().Local()
 		seconds := round(time.Now().Sub(startTime).Seconds())
@@ -67,6 +76,15 @@ func (c StatusFilter) Items(arg, data string) (items []alfred.Item, err error) {
 		})
 
 		items = append(items, item)
+
+		if entry.Pid != nil {
+			if warning, ok := budgetWarning(*entry.Pid); ok {
+				items = append(items, alfred.Item{
+					Title:    warning,
+					Subtitle: fmt.Sprintf("Over the %d%% budget warning threshold", getBudgetWarning()),
+				})
+			}
+		}
 	} else {
 		items = append(items, alfred.Item{
 			Title: "No timers currently running",
@@ -76,7 +94,7 @@ func (c StatusFilter) Items(arg, data string) (items []alfred.Item, err error) {
 
 	span, _ := getSpan("today")
 	var report *summaryReport
-	report, err = generateReport(span.Start, span.End, -1, "")
+	report, err = generateReport(span.Start, span.End, &reportCfg{})
 	for _, date := range report.dates {
 		items = append(items, alfred.Item{
 			Title: fmt.Sprintf("Total time for today: %s", formatDuration(date.total)),
@@ -86,3 +104,23 @@ func (c StatusFilter) Items(arg, data string) (items []alfred.Item, err error) {
 
 	return
 }
+
+// Do runs the command
+func (c StatusFilter) Do(data string) (out string, err error) {
+	if data == "dismissConflicts" {
+		updateQueue(func() {
+			queue.Conflicts = nil
+		})
+		return "Dismissed sync conflicts", nil
+	}
+
+	if data == "dismissNotice" {
+		cache.Notice = ""
+		if err = saveCache(); err != nil {
+			return
+		}
+		return "Dismissed notice", nil
+	}
+
+	return "", fmt.Errorf("Unrecognized input: %s", data)
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"syscall"
+
+	"github.com/jason0x43/go-alfred"
+)
+
+// Several workflow processes can run at once, so the cache and config files are
+// saved under a lock, written atomically, and merged with whatever another
+// process saved since this one loaded them. Only the values this process
+// changed are written over the file's current contents.
+
+// cacheBase and configBase are the cache and config as they were when last
+// loaded or saved by this process, in generic JSON form
+var cacheBase interface{}
+var configBase interface{}
+
+// migration upgrades the contents of a data file from one version to the next
+type migration func(doc map[string]interface{}) error
+
+// cacheMigrations upgrade older cache files. The current version of the cache
+// is the number of migrations.
+var cacheMigrations = []migration{
+	// Version 1 added the version number; older caches are otherwise
+	// compatible
+	func(doc map[string]interface{}) error { return nil },
+
+	// Version 2 added workspace billing details, so the next refresh is a full
+	// one
+	func(doc map[string]interface{}) error {
+		if sync, ok := doc["Sync"].(map[string]interface{}); ok {
+			delete(sync, "account")
+		}
+		return nil
+	},
+}
+
+// configMigrations upgrade older config files. The current version of the
+// config is the number of migrations.
+var configMigrations = []migration{
+	// Version 1 only added the version number
+	func(doc map[string]interface{}) error { return nil },
+
+	// Version 2 made a workday start hour of 0 mean midnight rather than the
+	// default of 9
+	func(doc map[string]interface{}) error {
+		if hour, ok := doc["WorkdayStartHour"].(json.Number); !ok || hour.String() == "0" {
+			doc["WorkdayStartHour"] = json.Number("9")
+		}
+		return nil
+	},
+}
+
+// absent marks a value that doesn't exist in one version of a merged document
+var absent = &struct{}{}
+
+// loadCache loads the cache file. A cache that can't be read is deleted so
+// that it will be rebuilt by the next refresh rather than merged with.
+func loadCache() (err error) {
+	if err = readJSON(cacheFile, &cache, cacheMigrations, true); err != nil && !os.IsNotExist(err) {
+		dlog.Printf("Error loading cache, rebuilding: %v", err)
+		discardCache()
+		resetValue(&cache)
+		cacheBase = toGeneric(&cache)
+		cache.Notice = fmt.Sprintf("%v", err)
+		return nil
+	}
+	cacheBase = toGeneric(&cache)
+	return
+}
+
+// discardCache deletes an unreadable cache file, unless another process has
+// replaced it with a readable one since it was loaded
+func discardCache() {
+	unlock, err := lockFile(cacheFile)
+	if err != nil {
+		dlog.Printf("Error discarding cache: %v", err)
+		return
+	}
+	defer unlock()
+
+	saved := reflect.New(reflect.TypeOf(cache)).Interface()
+	if err := readJSON(cacheFile, saved, cacheMigrations, true); err == nil {
+		return
+	}
+	if err := os.Remove(cacheFile); err != nil && !os.IsNotExist(err) {
+		dlog.Printf("Error discarding cache: %v", err)
+	}
+}
+
+// loadConfig loads the config file. Unlike the cache, the config can't be
+// rebuilt, so as much of it as possible is kept, including fields added by
+// newer versions of the workflow.
+func loadConfig() (err error) {
+	if err = readJSON(configFile, &config, configMigrations, false); os.IsNotExist(err) {
+		// A new config gets the defaults that migrations give older ones
+		doc := map[string]interface{}{}
+		if err = migrateDoc(configFile, doc, configMigrations, false); err == nil {
+			err = decodeDoc(configFile, doc, &config, false)
+		}
+	}
+	configBase = toGeneric(&config)
+	return
+}
+
+// noticeItems returns an item for a notice about the cached data, such as it
+// having been rebuilt
+func noticeItems() (items []alfred.Item) {
+	if cache.Notice != "" {
+		items = append(items, alfred.Item{
+			Title:    "Cached data was rebuilt",
+			Subtitle: cache.Notice,
+			Arg: &alfred.ItemArg{
+				Keyword: "status",
+				Mode:    alfred.ModeDo,
+				Data:    "dismissNotice",
+			},
+		})
+	}
+	return
+}
+
+// saveCache merges this process's changes to the cache into the cache file
+func saveCache() error {
+	cache.Version = len(cacheMigrations)
+	return saveMerged(cacheFile, &cache, &cacheBase, cacheMigrations)
+}
+
+// saveConfig merges this process's changes to the config into the config file.
+// A config written by a newer version of the workflow keeps its version.
+func saveConfig() error {
+	if config.Version < len(configMigrations) {
+		config.Version = len(configMigrations)
+	}
+	return saveMerged(configFile, &config, &configBase, configMigrations)
+}
+
+// saveMerged does a three-way merge of the changes between base and current
+// into the data saved at path, writes the result, and loads it into current
+func saveMerged(path string, current interface{}, base *interface{}, migrations []migration) (err error) {
+	var unlock func()
+	if unlock, err = lockFile(path); err != nil {
+		return
+	}
+	defer unlock()
+
+	// The saved data is merged as is, so fields this version doesn't know
+	// about are kept
+	theirs := *base
+	if doc, err := readDoc(path, migrations, false); err == nil {
+		theirs = doc
+	} else if !os.IsNotExist(err) {
+		dlog.Printf("Error loading %s for merge: %v", path, err)
+	}
+
+	merged := mergeValues(*base, toGeneric(current), theirs)
+
+	var data []byte
+	if data, err = json.MarshalIndent(merged, "", "\t"); err != nil {
+		return
+	}
+	if err = writeFileAtomic(path, data); err != nil {
+		return
+	}
+
+	// Pick up changes made by other processes
+	resetValue(current)
+	if err = json.Unmarshal(data, current); err != nil {
+		return
+	}
+	*base = toGeneric(current)
+
+	return
+}
+
+// updateJSON reloads a data file into value, applies a change to it, and saves
+// it, holding the file's lock throughout. It's used for files that are only
+// ever changed incrementally, like the offline queue.
+func updateJSON(path string, value interface{}, change func()) (err error) {
+	var unlock func()
+	if unlock, err = lockFile(path); err != nil {
+		return
+	}
+	defer unlock()
+
+	resetValue(value)
+	if err = alfred.LoadJSON(path, value); err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	change()
+	return writeJSON(path, value)
+}
+
+// writeJSON atomically saves a value to a data file. The caller should hold the
+// file's lock.
+func writeJSON(path string, value interface{}) (err error) {
+	var data []byte
+	if data, err = json.MarshalIndent(value, "", "\t"); err != nil {
+		return
+	}
+	return writeFileAtomic(path, data)
+}
+
+// readJSON loads a data file into value, first upgrading it with any
+// migrations that haven't been applied to it. A strict load fails if the file
+// was written by a newer version of the workflow or has fields that value
+// doesn't.
+func readJSON(path string, value interface{}, migrations []migration, strict bool) (err error) {
+	var doc map[string]interface{}
+	if doc, err = readDoc(path, migrations, strict); err != nil {
+		return
+	}
+	return decodeDoc(path, doc, value, strict)
+}
+
+// decodeDoc decodes a data file's generic JSON form into value. A strict
+// decode fails if the data has fields that value doesn't.
+func decodeDoc(path string, doc map[string]interface{}, value interface{}, strict bool) (err error) {
+	var data []byte
+	if data, err = json.Marshal(doc); err != nil {
+		return
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err = decoder.Decode(value); err != nil {
+		return fmt.Errorf("Error reading %s: %v", filepath.Base(path), err)
+	}
+
+	return
+}
+
+// readDoc loads a data file in generic JSON form and applies any migrations
+// that haven't been applied to it. A strict load fails if the file was written
+// by a newer version of the workflow.
+func readDoc(path string, migrations []migration, strict bool) (doc map[string]interface{}, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("Error reading %s: %v", filepath.Base(path), err)
+	}
+
+	if err = migrateDoc(path, doc, migrations, strict); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// migrateDoc applies the migrations that haven't been applied to a data file's
+// generic JSON form. A strict migration fails if the file was written by a
+// newer version of the workflow.
+func migrateDoc(path string, doc map[string]interface{}, migrations []migration, strict bool) (err error) {
+	version := getVersion(doc)
+	if version > len(migrations) && strict {
+		return fmt.Errorf("%s is from a newer version of this workflow (version %d)",
+			filepath.Base(path), version)
+	}
+
+	for ; version < len(migrations); version++ {
+		dlog.Printf("Migrating %s to version %d", path, version+1)
+		if err = migrations[version](doc); err != nil {
+			return fmt.Errorf("Error migrating %s to version %d: %v",
+				filepath.Base(path), version+1, err)
+		}
+		doc["Version"] = json.Number(strconv.Itoa(version + 1))
+	}
+
+	return
+}
+
+// getVersion returns the schema version of a data file, which is 0 for files
+// written before versions were added
+func getVersion(doc map[string]interface{}) int {
+	if number, ok := doc["Version"].(json.Number); ok {
+		if version, err := number.Int64(); err == nil {
+			return int(version)
+		}
+	}
+	return 0
+}
+
+// resetValue sets the value a pointer refers to to its zero value
+func resetValue(pointer interface{}) {
+	value := reflect.ValueOf(pointer).Elem()
+	value.Set(reflect.Zero(value.Type()))
+}
+
+// lockFile takes an exclusive lock for a data file, returning a function that
+// releases it
+func lockFile(path string) (unlock func(), err error) {
+	if unlock, err = flockFile(path, syscall.LOCK_EX); err != nil {
+		err = fmt.Errorf("Error locking %s: %v", path, err)
+	}
+	return
+}
+
+// tryLockFile takes an exclusive lock for a file if no other process holds
+// it. ok is false if the lock is held elsewhere.
+func tryLockFile(path string) (unlock func(), ok bool, err error) {
+	if unlock, err = flockFile(path, syscall.LOCK_EX|syscall.LOCK_NB); err == syscall.EWOULDBLOCK {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("Error locking %s: %v", path, err)
+	}
+	return unlock, true, nil
+}
+
+func flockFile(path string, how int) (unlock func(), err error) {
+	var file *os.File
+	if file, err = os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600); err != nil {
+		return
+	}
+
+	if err = syscall.Flock(int(file.Fd()), how); err != nil {
+		file.Close()
+		return
+	}
+
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place so
+// that readers never see a partially written file
+func writeFileAtomic(path string, data []byte) (err error) {
+	var file *os.File
+	if file, err = os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp"); err != nil {
+		return
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return
+	}
+	if err = file.Close(); err != nil {
+		return
+	}
+	if err = os.Chmod(file.Name(), 0600); err != nil {
+		return
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// toGeneric converts a value to the maps, slices and scalars it would decode to
+// from JSON
+func toGeneric(value interface{}) (generic interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		dlog.Printf("Error encoding %T: %v", value, err)
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&generic); err != nil {
+		dlog.Printf("Error decoding %T: %v", value, err)
+	}
+	return
+}
+
+// mergeValues returns theirs with the changes from base to ours applied.
+// Objects are merged key by key, and lists of objects with IDs are merged item
+// by item. Where both sides changed the same scalar value, ours wins.
+func mergeValues(base, ours, theirs interface{}) interface{} {
+	if reflect.DeepEqual(ours, base) {
+		return theirs
+	}
+	if reflect.DeepEqual(theirs, base) || reflect.DeepEqual(ours, theirs) {
+		return ours
+	}
+
+	if ourMap, ok := ours.(map[string]interface{}); ok {
+		if theirMap, ok := theirs.(map[string]interface{}); ok {
+			baseMap, _ := base.(map[string]interface{})
+			return mergeMaps(baseMap, ourMap, theirMap)
+		}
+	}
+
+	if ourList, ok := ours.([]interface{}); ok {
+		if theirList, ok := theirs.([]interface{}); ok {
+			baseList, _ := base.([]interface{})
+			if hasIDs(baseList) && hasIDs(ourList) && hasIDs(theirList) {
+				return mergeLists(baseList, ourList, theirList)
+			}
+		}
+	}
+
+	return ours
+}
+
+func mergeMaps(base, ours, theirs map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+
+	keys := map[string]bool{}
+	for _, m := range []map[string]interface{}{base, ours, theirs} {
+		for key := range m {
+			keys[key] = true
+		}
+	}
+
+	for key := range keys {
+		value := mergeValues(lookup(base, key), lookup(ours, key), lookup(theirs, key))
+		if value != absent {
+			merged[key] = value
+		}
+	}
+
+	return merged
+}
+
+func mergeLists(base, ours, theirs []interface{}) []interface{} {
+	baseItems := indexByID(base)
+	ourItems := indexByID(ours)
+	theirItems := indexByID(theirs)
+
+	merge := func(id string) interface{} {
+		return mergeValues(lookup(baseItems, id), lookup(ourItems, id), lookup(theirItems, id))
+	}
+
+	merged := []interface{}{}
+	for _, item := range theirs {
+		if value := merge(getID(item)); value != absent {
+			merged = append(merged, value)
+		}
+	}
+	for _, item := range ours {
+		id := getID(item)
+		if _, ok := theirItems[id]; ok {
+			continue
+		}
+		if value := merge(id); value != absent {
+			merged = append(merged, value)
+		}
+	}
+
+	return merged
+}
+
+func lookup(m map[string]interface{}, key string) interface{} {
+	if value, ok := m[key]; ok {
+		return value
+	}
+	return absent
+}
+
+func hasIDs(list []interface{}) bool {
+	for _, item := range list {
+		if getID(item) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func getID(item interface{}) string {
+	if m, ok := item.(map[string]interface{}); ok {
+		if id, ok := m["id"]; ok {
+			return fmt.Sprint(id)
+		}
+	}
+	return ""
+}
+
+func indexByID(list []interface{}) map[string]interface{} {
+	index := map[string]interface{}{}
+	for _, item := range list {
+		index[getID(item)] = item
+	}
+	return index
+}
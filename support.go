@@ -7,7 +7,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/jason0x43/go-alfred"
 	"github.com/jason0x43/go-toggl"
 )
 
@@ -17,7 +16,14 @@ func checkRefresh() error {
 		return nil
 	}
 
-	if time.Now().Sub(cache.Time).Minutes() < 5.0 {
+	// Queued changes should be synced as soon as possible
+	if !isStale() && !isQueueing() {
+		return nil
+	}
+
+	// Cached data can be shown while it's refreshed, but there must be some
+	if config.BackgroundRefresh && !cache.Time.IsZero() {
+		startBackgroundRefresh()
 		return nil
 	}
 
@@ -25,23 +31,22 @@ func checkRefresh() error {
 	err := refresh()
 	if err != nil {
 		dlog.Println("Error refreshing cache:", err)
+		if isOfflineError(err) {
+			// Work with the cached data until toggl.com is reachable
+			return nil
+		}
 	}
 	return err
 }
 
+// refresh updates the cache from toggl.com. Most of the time only changes
+// since the last sync are downloaded.
 func refresh() error {
-	s := toggl.OpenSession(config.APIKey)
-	account, err := s.GetAccount()
-	if err != nil {
-		return err
+	if needsFullRefresh() {
+		dlog.Println("Doing a full refresh")
+		return fullRefresh()
 	}
-
-	dlog.Printf("got account: %#v", account)
-
-	cache.Time = time.Now()
-	cache.Account = account
-	cache.Workspace = account.Workspaces[0].ID
-	return alfred.SaveJSON(cacheFile, &cache)
+	return syncCache()
 }
 
 func getRunningTimer() (timer toggl.TimeEntry, found bool) {
@@ -79,6 +84,24 @@ func findProjectByName(name string) (project toggl.Project, found bool) {
 	return
 }
 
+// matchProjectName finds an active project with a given name, ignoring case
+// and spaces. Projects in the active workspace are preferred over ones in
+// other visible workspaces.
+func matchProjectName(name string) (project toggl.Project, found bool) {
+	key := normalizeName(name)
+	for _, proj := range cache.Account.Projects {
+		if proj.IsActive() && isVisibleWorkspace(proj.Wid) && normalizeName(proj.Name) == key {
+			if proj.Wid == cache.Workspace {
+				return proj, true
+			}
+			if !found {
+				project, found = proj, true
+			}
+		}
+	}
+	return
+}
+
 func getProjectByID(id int) (project toggl.Project, index int, found bool) {
 	for i, proj := range cache.Account.Projects {
 		if proj.ID == id {
@@ -88,6 +111,43 @@ func getProjectByID(id int) (project toggl.Project, index int, found bool) {
 	return
 }
 
+func getProjectDetailsByID(id int) (details projectDetails, index int, found bool) {
+	for i, d := range cache.ProjectDetails {
+		if d.ID == id {
+			return d, i, true
+		}
+	}
+	return
+}
+
+func getWorkspaceDetailsByID(id int) (details workspaceDetails, index int, found bool) {
+	for i, d := range cache.WorkspaceDetails {
+		if d.ID == id {
+			return d, i, true
+		}
+	}
+	return
+}
+
+func getTaskByID(id int) (task projectTask, index int, found bool) {
+	for i, t := range cache.Tasks {
+		if t.ID == id {
+			return t, i, true
+		}
+	}
+	return
+}
+
+// findTasksByProjectID returns the active tasks in a project
+func findTasksByProjectID(pid int) (tasks []projectTask) {
+	for _, task := range cache.Tasks {
+		if task.Pid == pid && task.Active {
+			tasks = append(tasks, task)
+		}
+	}
+	return
+}
+
 func getTagByID(id int) (tag toggl.Tag, index int, found bool) {
 	for i, entry := range cache.Account.Tags[:] {
 		if entry.ID == id {
@@ -115,6 +175,26 @@ func getClientByID(id int) (client toggl.Client, index int, found bool) {
 	return
 }
 
+// getActiveWorkspace returns the ID of the workspace selected by the user, or
+// of the first workspace if no valid workspace has been selected
+func getActiveWorkspace(account toggl.Account) int {
+	for _, workspace := range account.Workspaces {
+		if workspace.ID == config.WorkspaceID {
+			return workspace.ID
+		}
+	}
+	if len(account.Workspaces) > 0 {
+		return account.Workspaces[0].ID
+	}
+	return 0
+}
+
+// isVisibleWorkspace returns true if projects, tags and timers in a workspace
+// should be listed
+func isVisibleWorkspace(wid int) bool {
+	return config.AllWorkspaces || wid == cache.Workspace
+}
+
 func getWorkspaceByID(id int) (workspace toggl.Workspace, index int, found bool) {
 	for i, workspace := range cache.Account.Workspaces {
 		if workspace.ID == id {
@@ -154,6 +234,18 @@ func findTagByName(name string) (tag toggl.Tag, found bool) {
 	return
 }
 
+// matchTagName finds a tag in a workspace with a given name, ignoring case
+// and spaces
+func matchTagName(name string, wid int) (tag toggl.Tag, found bool) {
+	key := normalizeName(name)
+	for _, tag := range cache.Account.Tags {
+		if tag.Wid == wid && normalizeName(tag.Name) == key {
+			return tag, true
+		}
+	}
+	return
+}
+
 func findTagNameByID(id int) (name string, found bool) {
 	for _, tag := range cache.Account.Tags {
 		if tag.ID == id {
@@ -240,6 +332,30 @@ func tagHasTimeEntries(tag string) bool {
 	return false
 }
 
+// normalizeName lowercases a name and removes its whitespace so that it can be
+// compared with single-word tokens
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), ""))
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, value int) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // is date1's date before date2's date
 func isDateBefore(date1 time.Time, date2 time.Time) bool {
 	return (date1.Year() == date2.Year() && date1.YearDay() < date2.YearDay()) ||
@@ -264,6 +380,90 @@ func isSameWeek(date1 time.Time, date2 time.Time) bool {
 	return y1 == y2 && w1 == w2
 }
 
+// parseClockTime parses a time of day like "9:15", "14:00", or "2:30pm"
+func parseClockTime(s string) (t time.Time, err error) {
+	s = strings.ToLower(s)
+	for _, layout := range []string{"15:04", "3:04pm", "3pm"} {
+		if t, err = time.Parse(layout, s); err == nil {
+			return
+		}
+	}
+	return t, fmt.Errorf("Invalid time %s", s)
+}
+
+// parseDateTime parses a time of day with an optional day, like "14:00",
+// "14:00 yesterday", "mon 14:00", or "2026-10-12 09:30". Relative days and
+// weekdays are relative to the given reference time, and without a day the
+// time is applied to the reference time's date.
+func parseDateTime(s string, ref time.Time) (t time.Time, err error) {
+	var clock string
+	var day time.Time
+	hasDay := false
+
+	for _, word := range strings.Fields(s) {
+		if d, ok := parseDay(word, ref); ok && !hasDay {
+			day = d
+			hasDay = true
+		} else if clock == "" {
+			clock = word
+		} else {
+			return t, fmt.Errorf("Invalid time %s", s)
+		}
+	}
+
+	var clockTime time.Time
+	if clockTime, err = parseClockTime(clock); err != nil {
+		return
+	}
+
+	if hasDay {
+		t = getNewTime(day, clockTime)
+	} else {
+		t = getNewTime(ref.Local(), clockTime)
+	}
+	return
+}
+
+// parseDay parses a day like "today", "yesterday", a weekday name ("mon" or
+// "monday"), or a date ("2026-10-12", "10/12"), returning the start of that
+// day. Weekdays refer to the most recent such day, and relative days are
+// relative to now.
+func parseDay(s string, now time.Time) (day time.Time, ok bool) {
+	s = strings.ToLower(s)
+
+	switch s {
+	case "today":
+		return toDayStart(now), true
+	case "yesterday":
+		return toDayStart(now).AddDate(0, 0, -1), true
+	}
+
+	if len(s) >= 3 {
+		for i := 0; i < 7; i++ {
+			weekday := time.Weekday(i)
+			if strings.HasPrefix(strings.ToLower(weekday.String()), s) {
+				delta := int(now.Weekday()) - i
+				if delta < 0 {
+					delta += 7
+				}
+				return toDayStart(now).AddDate(0, 0, -delta), true
+			}
+		}
+	}
+
+	if layout := getDateLayout(s); layout != "" {
+		if date, err := time.Parse(layout, s); err == nil {
+			year := date.Year()
+			if year == 0 {
+				year = now.Year()
+			}
+			return time.Date(year, date.Month(), date.Day(), 0, 0, 0, 0, time.Local), true
+		}
+	}
+
+	return
+}
+
 func toIsoDateString(date time.Time) string {
 	return date.Format("2006-01-02")
 }
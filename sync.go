@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jason0x43/go-toggl"
+)
+
+// Between full refreshes, the cache is kept up to date by asking toggl.com for
+// only the entries, projects, tags, clients and tasks that changed since they
+// were last synced. Deleted items are included in those responses with a
+// deletion time.
+
+// syncTimes records when each kind of data was last synced
+type syncTimes struct {
+	Account  time.Time `json:"account"`
+	Entries  time.Time `json:"entries"`
+	Projects time.Time `json:"projects"`
+	Tags     time.Time `json:"tags"`
+	Clients  time.Time `json:"clients"`
+	Tasks    time.Time `json:"tasks"`
+}
+
+// fullRefreshHours is how often the whole account is downloaded, which picks
+// up changes to workspaces and user settings
+const fullRefreshHours = 24
+
+// syncOverlap is subtracted from sync times so that changes made while a sync
+// was running aren't missed
+const syncOverlap = time.Minute
+
+// deletionInfo holds the fields used to tell if a synced item was deleted
+type deletionInfo struct {
+	ID              int        `json:"id"`
+	ServerDeletedAt *time.Time `json:"server_deleted_at"`
+	DeletedAt       *time.Time `json:"deleted_at"`
+}
+
+func (d deletionInfo) isDeleted() bool {
+	return d.ServerDeletedAt != nil || d.DeletedAt != nil
+}
+
+// fullRefresh downloads the entire account, replaying any queued changes first
+func fullRefresh() error {
+	start := time.Now()
+	s := toggl.OpenSession(config.APIKey)
+	account, err := s.GetAccount()
+	if err != nil {
+		return err
+	}
+
+	dlog.Printf("got account: %#v", account)
+
+	if isQueueing() {
+		replayQueue(&account)
+
+		// Get the server's version of everything that was replayed
+		if account, err = s.GetAccount(); err != nil {
+			return err
+		}
+
+		// Anything that couldn't be replayed should still be visible
+		applyQueue(&account)
+	}
+
+	cache.Sync = syncTimes{Account: start, Entries: start, Tags: start, Clients: start}
+
+	// Project and workspace details and tasks are nice to have, so a failure
+	// here isn't fatal; they'll be fully downloaded by the next sync
+	if details, err := getProjectDetails(); err == nil {
+		cache.ProjectDetails = details
+		cache.Sync.Projects = start
+	} else {
+		dlog.Printf("Error getting project details: %v", err)
+	}
+	if details, err := getWorkspaceDetails(); err == nil {
+		cache.WorkspaceDetails = details
+	} else {
+		dlog.Printf("Error getting workspace details: %v", err)
+	}
+	if tasks, err := getTasks(); err == nil {
+		cache.Tasks = tasks
+		cache.Sync.Tasks = start
+	} else {
+		dlog.Printf("Error getting tasks: %v", err)
+	}
+
+	cache.Time = time.Now()
+	cache.Account = account
+	cache.Workspace = getActiveWorkspace(account)
+	return saveCache()
+}
+
+// syncCache merges changes made since the last sync into the cache
+func syncCache() (err error) {
+	if err = syncEntries(); err != nil {
+		return
+	}
+	if err = syncProjects(); err != nil {
+		return
+	}
+	if err = syncTags(); err != nil {
+		return
+	}
+	if err = syncClients(); err != nil {
+		return
+	}
+	if err = syncTasks(); err != nil {
+		return
+	}
+
+	// Workspace details can't be synced incrementally, but there are few of
+	// them
+	if details, err := getWorkspaceDetails(); err == nil {
+		cache.WorkspaceDetails = details
+	} else {
+		dlog.Printf("Error getting workspace details: %v", err)
+	}
+
+	pruneCachedEntries()
+
+	cache.Time = time.Now()
+	return saveCache()
+}
+
+// needsFullRefresh returns true if the cache should be rebuilt from a full
+// download of the account
+func needsFullRefresh() bool {
+	// Queued changes are checked against the server's full state
+	if isQueueing() {
+		return true
+	}
+	return time.Now().Sub(cache.Sync.Account).Hours() >= fullRefreshHours
+}
+
+// getChanged requests the items at a /me path that changed since a given
+// time, or all of them if the time is zero
+func getChanged(path string, since time.Time) (items []json.RawMessage, err error) {
+	if !since.IsZero() {
+		path += fmt.Sprintf("?since=%d", since.Add(-syncOverlap).Unix())
+	}
+	err = apiRequest("GET", path, nil, &items)
+	return
+}
+
+func syncEntries() (err error) {
+	start := time.Now()
+	var items []json.RawMessage
+	if items, err = getChanged("/me/time_entries", cache.Sync.Entries); err != nil {
+		return
+	}
+
+	// Changes to entries from before the cached window belong in the history
+	// store, since they'll be pruned from the cache
+	windowStart := getWindowStart()
+	var oldChanged, oldDeleted []toggl.TimeEntry
+
+	adata := &cache.Account
+	for _, item := range items {
+		var info deletionInfo
+		var entry toggl.TimeEntry
+		if err = json.Unmarshal(item, &info); err != nil {
+			return
+		}
+		if err = json.Unmarshal(item, &entry); err != nil {
+			return
+		}
+
+		if entry.StartTime().Before(windowStart) {
+			if info.isDeleted() {
+				oldDeleted = append(oldDeleted, entry)
+			} else {
+				oldChanged = append(oldChanged, entry)
+			}
+		}
+
+		_, index, found := getTimerByID(info.ID)
+		if info.isDeleted() {
+			if found {
+				adata.TimeEntries = append(adata.TimeEntries[:index], adata.TimeEntries[index+1:]...)
+			}
+		} else if found {
+			adata.TimeEntries[index] = entry
+		} else {
+			adata.TimeEntries = append(adata.TimeEntries, entry)
+		}
+	}
+
+	updateHistoryEntries(oldChanged, oldDeleted)
+
+	dlog.Printf("synced %d changed time entries", len(items))
+	cache.Sync.Entries = start
+	return
+}
+
+func syncProjects() (err error) {
+	start := time.Now()
+	var items []json.RawMessage
+	if items, err = getChanged("/me/projects", cache.Sync.Projects); err != nil {
+		return
+	}
+
+	adata := &cache.Account
+	for _, item := range items {
+		var info deletionInfo
+		var project toggl.Project
+		var details projectDetails
+		if err = json.Unmarshal(item, &info); err != nil {
+			return
+		}
+		if err = json.Unmarshal(item, &project); err != nil {
+			return
+		}
+		if err = json.Unmarshal(item, &details); err != nil {
+			return
+		}
+		details.Fetched = start
+
+		_, index, found := getProjectByID(info.ID)
+		_, detailsIndex, hasDetails := getProjectDetailsByID(info.ID)
+
+		if info.isDeleted() {
+			if found {
+				adata.Projects = append(adata.Projects[:index], adata.Projects[index+1:]...)
+			}
+			if hasDetails {
+				cache.ProjectDetails = append(cache.ProjectDetails[:detailsIndex],
+					cache.ProjectDetails[detailsIndex+1:]...)
+			}
+			continue
+		}
+
+		if found {
+			adata.Projects[index] = project
+		} else {
+			adata.Projects = append(adata.Projects, project)
+		}
+		if hasDetails {
+			cache.ProjectDetails[detailsIndex] = details
+		} else {
+			cache.ProjectDetails = append(cache.ProjectDetails, details)
+		}
+	}
+
+	dlog.Printf("synced %d changed projects", len(items))
+	cache.Sync.Projects = start
+	return
+}
+
+func syncTags() (err error) {
+	start := time.Now()
+	var items []json.RawMessage
+	if items, err = getChanged("/me/tags", cache.Sync.Tags); err != nil {
+		return
+	}
+
+	adata := &cache.Account
+	for _, item := range items {
+		var info deletionInfo
+		var tag toggl.Tag
+		if err = json.Unmarshal(item, &info); err != nil {
+			return
+		}
+		if err = json.Unmarshal(item, &tag); err != nil {
+			return
+		}
+
+		_, index, found := getTagByID(info.ID)
+		if info.isDeleted() {
+			if found {
+				adata.Tags = append(adata.Tags[:index], adata.Tags[index+1:]...)
+			}
+		} else if found {
+			adata.Tags[index] = tag
+		} else {
+			adata.Tags = append(adata.Tags, tag)
+		}
+	}
+
+	dlog.Printf("synced %d changed tags", len(items))
+	cache.Sync.Tags = start
+	return
+}
+
+func syncClients() (err error) {
+	start := time.Now()
+	var items []json.RawMessage
+	if items, err = getChanged("/me/clients", cache.Sync.Clients); err != nil {
+		return
+	}
+
+	adata := &cache.Account
+	for _, item := range items {
+		var info deletionInfo
+		var client toggl.Client
+		if err = json.Unmarshal(item, &info); err != nil {
+			return
+		}
+		if err = json.Unmarshal(item, &client); err != nil {
+			return
+		}
+
+		_, index, found := getClientByID(info.ID)
+		if info.isDeleted() {
+			if found {
+				adata.Clients = append(adata.Clients[:index], adata.Clients[index+1:]...)
+			}
+		} else if found {
+			adata.Clients[index] = client
+		} else {
+			adata.Clients = append(adata.Clients, client)
+		}
+	}
+
+	dlog.Printf("synced %d changed clients", len(items))
+	cache.Sync.Clients = start
+	return
+}
+
+func syncTasks() (err error) {
+	start := time.Now()
+	var items []json.RawMessage
+	if items, err = getChanged("/me/tasks", cache.Sync.Tasks); err != nil {
+		return
+	}
+
+	for _, item := range items {
+		var info deletionInfo
+		var task projectTask
+		if err = json.Unmarshal(item, &info); err != nil {
+			return
+		}
+		if err = json.Unmarshal(item, &task); err != nil {
+			return
+		}
+
+		_, index, found := getTaskByID(info.ID)
+		if info.isDeleted() {
+			if found {
+				cache.Tasks = append(cache.Tasks[:index], cache.Tasks[index+1:]...)
+			}
+		} else if found {
+			cache.Tasks[index] = task
+		} else {
+			cache.Tasks = append(cache.Tasks, task)
+		}
+	}
+
+	dlog.Printf("synced %d changed tasks", len(items))
+	cache.Sync.Tasks = start
+	return
+}
+
+// getWindowStart returns the start of the window of time entries covered by a
+// full refresh
+func getWindowStart() time.Time {
+	return toDayStart(time.Now()).AddDate(0, 0, -8)
+}
+
+// pruneCachedEntries drops entries that have fallen out of the window covered
+// by a full refresh, other than a running entry. Older entries are read from
+// the history store.
+func pruneCachedEntries() {
+	windowStart := getWindowStart()
+
+	var kept []toggl.TimeEntry
+	for _, entry := range cache.Account.TimeEntries {
+		if entry.IsRunning() || !entry.StartTime().Before(windowStart) {
+			kept = append(kept, entry)
+		}
+	}
+	cache.Account.TimeEntries = kept
+}
@@ -52,7 +52,7 @@ func (c TagCommand) Items(arg, data string) (items []alfred.Item, err error) {
 		tagCfg := tagCfg{}
 
 		for _, entry := range cache.Account.Tags {
-			if alfred.FuzzyMatches(entry.Name, arg) {
+			if isVisibleWorkspace(entry.Wid) && alfred.FuzzyMatches(entry.Name, arg) {
 				tagCfg.Tag = &entry.ID
 
 				items = append(items, alfred.Item{
@@ -91,6 +91,8 @@ func (c TagCommand) Items(arg, data string) (items []alfred.Item, err error) {
 
 // Do runs the command
 func (c TagCommand) Do(data string) (out string, err error) {
+	defer func() { saveUndoAction(out, err) }()
+
 	var cfg tagCfg
 
 	if data != "" {
@@ -108,16 +110,7 @@ func (c TagCommand) Do(data string) (out string, err error) {
 	}
 
 	if cfg.ToCreate != nil {
-		var tag toggl.Tag
-		if cfg.ToCreate.WID == 0 {
-			cfg.ToCreate.WID = cache.Account.Workspaces[0].ID
-		}
-		if tag, err = session.CreateTag(cfg.ToCreate.Name, cfg.ToCreate.WID); err == nil {
-			cache.Account.Tags = append(cache.Account.Tags, tag)
-			if err := alfred.SaveJSON(cacheFile, &cache); err != nil {
-				log.Printf("Error saving cache: %s\n", err)
-			}
-		}
+		_, err = createTag(cfg.ToCreate)
 
 		if out != "" {
 			out += ", created tag"
@@ -136,14 +129,21 @@ func (c TagCommand) Do(data string) (out string, err error) {
 			return
 		}
 
+		var tagged []int
+		for _, entry := range findTimersByTag(tag.Name) {
+			tagged = append(tagged, entry.ID)
+		}
+
 		if _, err = session.DeleteTag(tag); err == nil {
+			recordUndo(undoStep{Op: undoRestoreTag, Tag: &tag, EntryIDs: tagged})
+
 			adata := &cache.Account
 			if index < len(adata.Tags)-1 {
 				adata.Tags = append(adata.Tags[:index], adata.Tags[index+1:]...)
 			} else {
 				adata.Tags = adata.Tags[:index]
 			}
-			if err := alfred.SaveJSON(cacheFile, &cache); err != nil {
+			if err := saveCache(); err != nil {
 				dlog.Printf("Error saving cache: %s\n", err)
 			}
 		}
@@ -177,6 +177,24 @@ type createTagMessage struct {
 	WID  int
 }
 
+func createTag(msg *createTagMessage) (tag toggl.Tag, err error) {
+	session := toggl.OpenSession(config.APIKey)
+
+	if msg.WID == 0 {
+		msg.WID = cache.Workspace
+	}
+
+	if tag, err = session.CreateTag(msg.Name, msg.WID); err == nil {
+		recordUndo(undoStep{Op: undoDeleteTag, Tag: &tag})
+		cache.Account.Tags = append(cache.Account.Tags, tag)
+		if err := saveCache(); err != nil {
+			log.Printf("Error saving cache: %s\n", err)
+		}
+	}
+
+	return
+}
+
 func tagItems(tag toggl.Tag, arg string) (items []alfred.Item, err error) {
 	if alfred.FuzzyMatches("timers", arg) {
 		items = append(items, alfred.Item{
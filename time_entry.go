@@ -57,12 +57,24 @@ func (c TimeEntryCommand) Items(arg, data string) (items []alfred.Item, err erro
 		tid = *cfg.Timer
 	}
 
+	// Logging a completed time entry; only from the top-level list so that
+	// submenus can still filter on text starting with "log"
+	if data == "" {
+		if logQuery, ok := getLogQuery(arg); ok {
+			return logEntryItems(logQuery)
+		}
+	}
+
 	// Starting a new timer, still needs something
 	if cfg.ToStart != nil {
 		toStart := cfg.ToStart
+		if toStart.ChooseTask {
+			return taskPickerItems(*toStart, arg), nil
+		}
 		if toStart.Pid == 0 {
 			for _, proj := range cache.Account.Projects {
-				if proj.IsActive() && alfred.FuzzyMatches(proj.Name, arg) {
+				if proj.IsActive() && isVisibleWorkspace(proj.Wid) &&
+					alfred.FuzzyMatches(proj.Name, arg) {
 					toStart.Pid = proj.ID
 					item := alfred.Item{
 						UID:          fmt.Sprintf("%s.project.%d", workflow.BundleID(), proj.ID),
@@ -74,6 +86,15 @@ func (c TimeEntryCommand) Items(arg, data string) (items []alfred.Item, err erro
 							Data:    alfred.Stringify(timerCfg{ToStart: toStart}),
 						},
 					}
+
+					// Projects with tasks lead to a task picker
+					if len(findTasksByProjectID(proj.ID)) > 0 {
+						withTask := *toStart
+						withTask.ChooseTask = true
+						item.Subtitle = "Choose a task..."
+						item.Arg.Mode = alfred.ModeTell
+						item.Arg.Data = alfred.Stringify(timerCfg{ToStart: &withTask})
+					}
 					item.AddCheckBox(false)
 
 					item.AddMod(alfred.ModCmd, alfred.ItemMod{
@@ -96,6 +117,9 @@ func (c TimeEntryCommand) Items(arg, data string) (items []alfred.Item, err erro
 	if tid != -1 {
 		// Do someting with a specific time entry
 		if entry, _, ok := getTimerByID(tid); ok {
+			if len(cfg.MergeWith) > 0 {
+				return mergePickerItems(entry, cfg.MergeWith, arg), nil
+			}
 			items, err = timeEntryItems(&entry, arg)
 			return
 		}
@@ -138,7 +162,7 @@ func (c TimeEntryCommand) Items(arg, data string) (items []alfred.Item, err erro
 
 	var filtered []toggl.TimeEntry
 	for _, entry := range entries {
-		if alfred.FuzzyMatches(entry.Description, arg) {
+		if isVisibleWorkspace(entry.Wid) && alfred.FuzzyMatches(entry.Description, arg) {
 			filtered = append(filtered, entry)
 		}
 	}
@@ -146,6 +170,13 @@ func (c TimeEntryCommand) Items(arg, data string) (items []alfred.Item, err erro
 	if len(filtered) > 0 {
 		sort.Sort(sort.Reverse(byTime(filtered)))
 
+		mergeGroups := map[int][]int{}
+		for _, group := range findMergeGroups(cache.Account.TimeEntries) {
+			for _, id := range group {
+				mergeGroups[id] = group
+			}
+		}
+
 		for _, entry := range filtered {
 			item := alfred.Item{
 				Title:        entry.Description,
@@ -203,6 +234,21 @@ func (c TimeEntryCommand) Items(arg, data string) (items []alfred.Item, err erro
 				}
 			}
 
+			if isQueued(entry.ID) {
+				item.Subtitle += " (not synced)"
+			}
+
+			if group, ok := mergeGroups[entry.ID]; ok {
+				item.AddMod(alfred.ModAlt, alfred.ItemMod{
+					Subtitle: fmt.Sprintf("Merge %d consecutive entries for this task", len(group)),
+					Arg: &alfred.ItemArg{
+						Keyword: "timers",
+						Mode:    alfred.ModeDo,
+						Data:    alfred.Stringify(timerCfg{ToMerge: [][]int{group}}),
+					},
+				})
+			}
+
 			if entry.IsRunning() {
 				item.Icon = "icon.png"
 			}
@@ -223,35 +269,82 @@ func (c TimeEntryCommand) Items(arg, data string) (items []alfred.Item, err erro
 	}
 
 	if arg != "" {
-		// Arg is the new project's description
+		// Arg is the new time entry's description, optionally followed by a
+		// project, tags, and a billable flag
 
-		if pid == -1 && config.DefaultProjectID != 0 {
-			pid = config.DefaultProjectID
-		}
+		newTimer := newStartDesc(parseQuickEntry(arg))
 
-		newTimer := startDesc{Description: arg}
-		if pid != -1 {
-			newTimer.Pid = pid
+		if newTimer.Pid == 0 && newTimer.NewProject == "" {
+			if pid == -1 && config.DefaultProjectID != 0 {
+				pid = config.DefaultProjectID
+			}
+			if pid != -1 {
+				newTimer.Pid = pid
+			}
 		}
 
 		subtitle := "New entry"
-		if pid != -1 {
-			project, _, _ := getProjectByID(pid)
+		if newTimer.Pid != 0 {
+			project, _, _ := getProjectByID(newTimer.Pid)
 			subtitle += " in " + project.Name
+			if project.Wid != cache.Workspace {
+				workspace, _, _ := getWorkspaceByID(project.Wid)
+				subtitle += " (" + workspace.Name + ")"
+			}
+		} else if newTimer.NewProject != "" {
+			subtitle += " in " + newTimer.NewProject
+		}
+
+		var tags []string
+		tags = append(tags, newTimer.Tags...)
+		tags = append(tags, newTimer.NewTags...)
+		if len(tags) > 0 {
+			subtitle += ", tagged " + strings.Join(tags, ", ")
+		}
+
+		if newTimer.Billable {
+			subtitle += ", billable"
+		}
+
+		if newTimer.Start != nil {
+			subtitle += fmt.Sprintf(", started %s at %s", toHumanDateString(*newTimer.Start),
+				newTimer.Start.Local().Format("15:04"))
+		}
+
+		var toCreate []string
+		if newTimer.NewProject != "" {
+			toCreate = append(toCreate, fmt.Sprintf(`project "%s"`, newTimer.NewProject))
+		}
+		for _, tag := range newTimer.NewTags {
+			toCreate = append(toCreate, fmt.Sprintf(`tag "%s"`, tag))
+		}
+		if len(toCreate) > 0 {
+			subtitle += "; will create " + strings.Join(toCreate, ", ")
+		}
+
+		if running, isRunning := getRunningTimer(); isRunning && running.Pid != nil {
+			if warning, ok := budgetWarning(*running.Pid); ok {
+				subtitle += "; " + warning
+			}
 		}
 
 		defaultMode := alfred.ModeDo
 		altMode := alfred.ModeTell
 		altTitle := "Choose project..."
 
-		if pid == -1 && config.AskForProject {
+		if newTimer.Pid == 0 && newTimer.NewProject == "" && config.AskForProject {
 			defaultMode, altMode = altMode, defaultMode
 			altTitle = "Start with default (or no) project"
 			subtitle += ", press Enter to choose a project"
 		}
 
+		title := newTimer.Description
+		if title == "" {
+			title = arg
+		}
+
 		item := alfred.Item{
-			Title:    arg,
+			Title:    title,
 			Icon:     "off.png",
 			Subtitle: subtitle,
 			Arg: &alfred.ItemArg{
@@ -261,7 +354,21 @@ func (c TimeEntryCommand) Items(arg, data string) (items []alfred.Item, err erro
 			},
 		}
 
+		if newTimer.Pid != 0 && len(findTasksByProjectID(newTimer.Pid)) > 0 {
+			withTask := newTimer
+			withTask.ChooseTask = true
+			item.AddMod(alfred.ModCtrl, alfred.ItemMod{
+				Subtitle: "Choose a task...",
+				Arg: &alfred.ItemArg{
+					Keyword: "timers",
+					Mode:    alfred.ModeTell,
+					Data:    alfred.Stringify(timerCfg{ToStart: &withTask}),
+				},
+			})
+		}
+
 		newTimer.Pid = 0
+		newTimer.NewProject = ""
 
 		item.AddMod(alfred.ModCmd, alfred.ItemMod{
 			Subtitle: altTitle,
@@ -289,7 +396,33 @@ func (c TimeEntryCommand) Items(arg, data string) (items []alfred.Item, err erro
 		}
 	}
 
+	if pid == -1 && tag == "" && arg == "" {
+		items = append(append(noticeItems(), queueItems()...), items...)
+	}
+
 	if pid != -1 && arg == "" {
+		var groups [][]int
+		var fragments int
+		for _, group := range findMergeGroups(cache.Account.TimeEntries) {
+			if entry, _, ok := getTimerByID(group[0]); ok && entry.Pid != nil && *entry.Pid == pid {
+				groups = append(groups, group)
+				fragments += len(group)
+			}
+		}
+
+		if len(groups) > 0 {
+			items = alfred.InsertItem(items, alfred.Item{
+				Title: "Merge fragments",
+				Subtitle: fmt.Sprintf("Merge %d consecutive time entries into %d", fragments,
+					len(groups)),
+				Arg: &alfred.ItemArg{
+					Keyword: "timers",
+					Mode:    alfred.ModeDo,
+					Data:    alfred.Stringify(timerCfg{ToMerge: groups}),
+				},
+			}, 0)
+		}
+
 		project, _, _ := getProjectByID(pid)
 		items = alfred.InsertItem(items, alfred.Item{
 			Title:    fmt.Sprintf("%s time entries", project.Name),
@@ -306,6 +439,8 @@ func (c TimeEntryCommand) Items(arg, data string) (items []alfred.Item, err erro
 
 // Do runs the command
 func (c TimeEntryCommand) Do(data string) (out string, err error) {
+	defer func() { saveUndoAction(out, err) }()
+
 	var cfg timerCfg
 
 	if data != "" {
@@ -332,6 +467,36 @@ func (c TimeEntryCommand) Do(data string) (out string, err error) {
 		return fmt.Sprintf(`Started time entry "%s"`, timer.Description), nil
 	}
 
+	if cfg.ToLog != nil {
+		dlog.Printf("logging entry %v", cfg.ToLog)
+		var timer toggl.TimeEntry
+		if timer, err = logTimeEntry(*cfg.ToLog); err != nil {
+			return
+		}
+		return fmt.Sprintf(`Logged time entry "%s"`, timer.Description), nil
+	}
+
+	if cfg.ToSplit != nil {
+		dlog.Printf("splitting entry %v", cfg.ToSplit)
+		var timer toggl.TimeEntry
+		if timer, err = splitTimeEntry(*cfg.ToSplit); err != nil {
+			return
+		}
+		return fmt.Sprintf(`Split time entry, started "%s"`, timer.Description), nil
+	}
+
+	if cfg.ToMerge != nil {
+		dlog.Printf("merging entries %v", cfg.ToMerge)
+		var merged int
+		for _, group := range cfg.ToMerge {
+			if _, err = mergeTimeEntries(group); err != nil {
+				return
+			}
+			merged += len(group)
+		}
+		return fmt.Sprintf(`Merged %d time entries`, merged), nil
+	}
+
 	if cfg.ToToggle != nil {
 		dlog.Printf("toggling entry %v", cfg.ToToggle)
 		var timer toggl.TimeEntry
@@ -367,6 +532,46 @@ func (c TimeEntryCommand) Do(data string) (out string, err error) {
 
 // support -------------------------------------------------------------------
 
+// taskPickerItems lists the tasks in a new timer's project
+func taskPickerItems(toStart startDesc, arg string) (items []alfred.Item) {
+	toStart.ChooseTask = false
+
+	for _, task := range findTasksByProjectID(toStart.Pid) {
+		if !alfred.FuzzyMatches(task.Name, arg) {
+			continue
+		}
+
+		withTask := toStart
+		withTask.Tid = task.ID
+		items = append(items, alfred.Item{
+			UID:          fmt.Sprintf("%s.task.%d", workflow.BundleID(), task.ID),
+			Title:        task.Name,
+			Autocomplete: task.Name,
+			Arg: &alfred.ItemArg{
+				Keyword: "timers",
+				Mode:    alfred.ModeDo,
+				Data:    alfred.Stringify(timerCfg{ToStart: &withTask}),
+			},
+		})
+	}
+
+	alfred.FuzzySort(items, arg)
+
+	if alfred.FuzzyMatches("no task", arg) {
+		items = append(items, alfred.Item{
+			Title:    "No task",
+			Subtitle: "Start the timer without a task",
+			Arg: &alfred.ItemArg{
+				Keyword: "timers",
+				Mode:    alfred.ModeDo,
+				Data:    alfred.Stringify(timerCfg{ToStart: &toStart}),
+			},
+		})
+	}
+
+	return
+}
+
 type timerCfg struct {
 	Timer    *int             `json:"timer,omitempty"`
 	Property *string          `json:"property,omitempty"`
@@ -374,10 +579,16 @@ type timerCfg struct {
 	Billable *int             `json:"billable,omitempty"`
 	Tag      *int             `json:"tag,omitempty"`
 	ToStart  *startDesc       `json:"tostart,omitempty"`
+	ToLog    *startDesc       `json:"tolog,omitempty"`
 	ToUpdate *toggl.TimeEntry `json:"toupdate,omitempty"`
 	ToDelete *int             `json:"todelete,omitempty"`
 	ToUnstop *int             `json:"tounstop,omitempty"`
+	ToSplit  *splitCfg        `json:"tosplit,omitempty"`
+	ToMerge  [][]int          `json:"tomerge,omitempty"`
 	ToToggle *toggleCfg       `json:"totoggle,omitempty"`
+
+	// MergeWith holds the entries chosen so far to be merged with Timer
+	MergeWith []int `json:"mergewith,omitempty"`
 }
 
 type toggleCfg struct {
@@ -385,9 +596,125 @@ type toggleCfg struct {
 	DurationOnly bool `json:"durationOnly"`
 }
 
+type splitCfg struct {
+	Timer  int       `json:"timer"`
+	Second startDesc `json:"second"`
+}
+
 type startDesc struct {
-	Description string `json:"description"`
-	Pid         int    `json:"pid"`
+	Description string     `json:"description"`
+	Pid         int        `json:"pid"`
+	Tid         int        `json:"tid,omitempty"`
+	ChooseTask  bool       `json:"chooseTask,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Billable    bool       `json:"billable,omitempty"`
+	NewProject  string     `json:"newProject,omitempty"`
+	NewTags     []string   `json:"newTags,omitempty"`
+	Start       *time.Time `json:"start,omitempty"`
+	Stop        *time.Time `json:"stop,omitempty"`
+}
+
+// quickEntry is a new time entry parsed from a query like
+// "fix login @Backend #bug #urgent $ since 9:15"
+type quickEntry struct {
+	Description string
+	Project     string
+	Tags        []string
+	Billable    bool
+	Start       *time.Time
+}
+
+// parseQuickEntry splits a query into a description and the @project, #tag,
+// and $ (billable) tokens mixed into it. A start time in the past may be given
+// at the end of the query as "-20m", "since 9:15", or "at 14:00 yesterday".
+func parseQuickEntry(query string) (q quickEntry) {
+	var words []string
+	q, words = splitQuickEntry(query)
+	words, q.Start = parseStartSuffix(words, time.Now())
+	q.Description = strings.Join(words, " ")
+	return
+}
+
+// splitQuickEntry pulls the @project, #tag, and $ tokens out of a query,
+// returning the remaining words
+func splitQuickEntry(query string) (q quickEntry, words []string) {
+
+	for _, word := range strings.Fields(query) {
+		switch {
+		case word == "$":
+			q.Billable = true
+		case len(word) > 1 && word[0] == '@':
+			q.Project = word[1:]
+		case len(word) > 1 && word[0] == '#':
+			q.Tags = append(q.Tags, word[1:])
+		default:
+			words = append(words, word)
+		}
+	}
+
+	return
+}
+
+var agoFormat = regexp.MustCompile(`^-(\d+h)?(\d+m)?$`)
+
+// parseStartSuffix looks for a past start time at the end of a list of words,
+// returning the remaining words and the start time if one was found
+func parseStartSuffix(words []string, now time.Time) ([]string, *time.Time) {
+	n := len(words)
+	if n == 0 {
+		return words, nil
+	}
+
+	if last := words[n-1]; len(last) > 1 && agoFormat.MatchString(last) {
+		if d, err := time.ParseDuration(last[1:]); err == nil {
+			start := now.Add(-d)
+			return words[:n-1], &start
+		}
+	}
+
+	for i := n - 2; i >= 0 && i >= n-3; i-- {
+		if word := strings.ToLower(words[i]); word == "since" || word == "at" {
+			start, err := parseDateTime(strings.Join(words[i+1:], " "), now.Truncate(time.Minute))
+			if err == nil && !start.After(now) {
+				return words[:i], &start
+			}
+			break
+		}
+	}
+
+	return words, nil
+}
+
+// newStartDesc resolves the project and tags named in a quick entry. Names
+// that don't match an existing project or tag are marked for creation.
+func newStartDesc(q quickEntry) (desc startDesc) {
+	desc.Description = q.Description
+	desc.Billable = q.Billable
+	desc.Start = q.Start
+
+	// Tags are looked up in the workspace the entry will be created in
+	wid := cache.Workspace
+
+	if q.Project != "" {
+		if project, ok := matchProjectName(q.Project); ok {
+			desc.Pid = project.ID
+			wid = project.Wid
+		} else {
+			desc.NewProject = q.Project
+		}
+	}
+
+	for _, name := range q.Tags {
+		if tag, ok := matchTagName(name, wid); ok {
+			if !containsString(desc.Tags, tag.Name) {
+				desc.Tags = append(desc.Tags, tag.Name)
+			}
+		} else if !containsString(desc.NewTags, name) {
+			desc.NewTags = append(desc.NewTags, name)
+		}
+	}
+
+	return
 }
 
 func deleteTimeEntry(id int) (entry toggl.TimeEntry, err error) {
@@ -398,15 +725,26 @@ func deleteTimeEntry(id int) (entry toggl.TimeEntry, err error) {
 		return
 	}
 
+	if isQueueing() {
+		queueEntryDelete(entry)
+		return
+	}
+
 	session := toggl.OpenSession(config.APIKey)
-	if _, err = session.DeleteTimeEntry(entry); err == nil {
+	if _, err = session.DeleteTimeEntry(entry); isOfflineError(err) {
+		queueEntryDelete(entry)
+		err = nil
+	} else if err == nil {
+		recordUndo(undoStep{Op: undoRestoreEntry, Entry: &entry})
+		updateHistoryEntry(entry, true)
+
 		adata := &cache.Account
 		if index < len(adata.TimeEntries)-1 {
 			adata.TimeEntries = append(adata.TimeEntries[:index], adata.TimeEntries[index+1:]...)
 		} else {
 			adata.TimeEntries = adata.TimeEntries[:index]
 		}
-		if err := alfred.SaveJSON(cacheFile, &cache); err != nil {
+		if err := saveCache(); err != nil {
 			dlog.Printf("Error saving cache: %s\n", err)
 		}
 	}
@@ -414,30 +752,128 @@ func deleteTimeEntry(id int) (entry toggl.TimeEntry, err error) {
 	return
 }
 
-func startTimeEntry(desc startDesc) (entry toggl.TimeEntry, err error) {
-	session := toggl.OpenSession(config.APIKey)
+// newTimeEntry creates any missing project and tags for a new time entry and
+// returns a running entry for the description
+func newTimeEntry(desc startDesc) (entry toggl.TimeEntry, err error) {
+	if desc.NewProject != "" {
+		var project toggl.Project
+		if project, err = createProject(&createProjectMessage{Name: desc.NewProject}); err != nil {
+			return
+		}
+		desc.Pid = project.ID
+	}
+
+	// Entries belong to the workspace of their project, if they have one
+	wid := cache.Workspace
+	if desc.Pid != 0 {
+		if project, _, ok := getProjectByID(desc.Pid); ok {
+			wid = project.Wid
+		}
+	}
+
+	for _, name := range desc.NewTags {
+		if _, err = createTag(&createTagMessage{Name: name, WID: wid}); err != nil {
+			return
+		}
+		desc.Tags = append(desc.Tags, name)
+	}
+
+	start := time.Now()
+	if desc.Start != nil {
+		start = *desc.Start
+	}
+
+	entry = toggl.TimeEntry{
+		Wid:         wid,
+		Description: desc.Description,
+		Tags:        desc.Tags,
+		Duration:    -1,
+		Billable:    desc.Billable,
+	}
+	entry.SetStartTime(start, false)
+
+	if desc.Tid != 0 {
+		entry.Tid = &desc.Tid
+	}
 
 	if desc.Pid != 0 {
 		project, _, _ := getProjectByID(desc.Pid)
-		entry, err = session.StartTimeEntryForProject(
-			desc.Description,
-			cache.Workspace,
-			desc.Pid,
-			project.Billable,
-		)
-	} else {
-		entry, err = session.StartTimeEntry(desc.Description, cache.Workspace)
+		entry.Pid = &desc.Pid
+		if project.Billable != nil && *project.Billable {
+			entry.Billable = true
+		}
+	}
+
+	return
+}
+
+// addTimeEntry creates a time entry and adds it to the cache
+func addTimeEntry(newEntry toggl.TimeEntry) (entry toggl.TimeEntry, err error) {
+	if isQueueing() {
+		return queueNewEntry(newEntry), nil
 	}
 
-	if err == nil {
+	if entry, err = createTimeEntry(newEntry); isOfflineError(err) {
+		return queueNewEntry(newEntry), nil
+	} else if err == nil {
 		dlog.Printf("Got entry: %#v\n", entry)
+		recordUndo(undoStep{Op: undoDeleteEntry, Entry: &entry})
+		updateHistoryEntry(entry, false)
 		cache.Account.TimeEntries = append(cache.Account.TimeEntries, entry)
-		if err := alfred.SaveJSON(cacheFile, &cache); err != nil {
+		if err := saveCache(); err != nil {
 			dlog.Printf("Error saving cache: %s\n", err)
 		}
 	}
 
-	return entry, nil
+	return
+}
+
+func startTimeEntry(desc startDesc) (entry toggl.TimeEntry, err error) {
+	if desc.Start != nil {
+		start := *desc.Start
+
+		// A running timer should end where the backdated one begins
+		if running, isRunning := getRunningTimer(); isRunning {
+			if start.Before(running.StartTime()) {
+				err = fmt.Errorf(`Start time is before running time entry "%s"`, running.Description)
+				return
+			}
+
+			stopped := running.Copy()
+			stopped.Stop = &start
+			stopped.Duration = int64(start.Sub(running.StartTime()) / time.Second)
+			if _, err = updateTimeEntry(stopped); err != nil {
+				return
+			}
+		}
+	}
+
+	var newEntry toggl.TimeEntry
+	if newEntry, err = newTimeEntry(desc); err != nil {
+		return
+	}
+
+	return addTimeEntry(newEntry)
+}
+
+// logTimeEntry creates a completed time entry
+func logTimeEntry(desc startDesc) (entry toggl.TimeEntry, err error) {
+	if desc.Start == nil || desc.Stop == nil {
+		err = fmt.Errorf("A logged time entry needs a start and stop time")
+		return
+	}
+
+	var newEntry toggl.TimeEntry
+	if newEntry, err = newTimeEntry(desc); err != nil {
+		return
+	}
+
+	newEntry.Duration = 0
+	if err = newEntry.SetStopTime(*desc.Stop); err != nil {
+		return
+	}
+
+	return addTimeEntry(newEntry)
 }
 
 func toggleTimeEntry(toToggle toggleCfg) (updatedEntry toggl.TimeEntry, err error) {
@@ -450,25 +886,40 @@ func toggleTimeEntry(toToggle toggleCfg) (updatedEntry toggl.TimeEntry, err erro
 		return
 	}
 
+	if isQueueing() {
+		return queueToggle(entry, toToggle.DurationOnly), nil
+	}
+
 	running, isRunning := getRunningTimer()
 	session := toggl.OpenSession(config.APIKey)
 
 	if entry.IsRunning() {
-		if updatedEntry, err = session.StopTimeEntry(entry); err != nil {
-			return
-		}
+		updatedEntry, err = session.StopTimeEntry(entry)
 	} else {
-		if updatedEntry, err = session.ContinueTimeEntry(entry, toToggle.DurationOnly); err != nil {
-			return
-		}
+		updatedEntry, err = session.ContinueTimeEntry(entry, toToggle.DurationOnly)
+	}
+	if isOfflineError(err) {
+		return queueToggle(entry, toToggle.DurationOnly), nil
+	} else if err != nil {
+		return
 	}
 
 	adata := &cache.Account
 
 	if updatedEntry.ID == entry.ID {
 		adata.TimeEntries[index] = updatedEntry
+		if entry.IsRunning() {
+			recordUndo(undoStep{Op: undoUnstopEntry, Entry: &updatedEntry})
+		} else {
+			recordUndo(undoStep{Op: undoRevertEntry, Entry: &entry})
+		}
 	} else {
 		adata.TimeEntries = append(adata.TimeEntries, updatedEntry)
+		recordUndo(undoStep{Op: undoDeleteEntry, Entry: &updatedEntry})
+		if updatedEntry.StartTime().Equal(entry.StartTime()) {
+			// The original entry was replaced by an unstopped copy
+			recordUndo(undoStep{Op: undoRestoreEntry, Entry: &entry})
+		}
 	}
 
 	if isRunning && running.ID != updatedEntry.ID {
@@ -477,8 +928,13 @@ func toggleTimeEntry(toToggle toggleCfg) (updatedEntry toggl.TimeEntry, err erro
 			log.Printf("Error refreshing: %v\n", err)
 			return
 		}
+
+		// The previously running timer was stopped by toggl.com
+		if stopped, _, ok := getTimerByID(running.ID); ok && !stopped.IsRunning() {
+			recordUndo(undoStep{Op: undoUnstopEntry, Entry: &stopped})
+		}
 	} else {
-		if err = alfred.SaveJSON(cacheFile, &cache); err != nil {
+		if err = saveCache(); err != nil {
 			log.Printf("Error saving cache: %v\n", err)
 			return
 		}
@@ -496,6 +952,11 @@ func unstopTimeEntry(id int) (newEntry toggl.TimeEntry, err error) {
 		return
 	}
 
+	if isQueueing() {
+		err = fmt.Errorf("Time entries can't be unstopped until queued changes are synced")
+		return
+	}
+
 	session := toggl.OpenSession(config.APIKey)
 	newEntry, err = session.UnstopTimeEntry(entry)
 	adata := &cache.Account
@@ -504,10 +965,13 @@ func unstopTimeEntry(id int) (newEntry toggl.TimeEntry, err error) {
 		// Append the new time entry
 		if newEntry.ID != 0 {
 			cache.Account.TimeEntries = append(cache.Account.TimeEntries, newEntry)
+			recordUndo(undoStep{Op: undoDeleteEntry, Entry: &newEntry})
 		}
 	}
 
 	if err == nil || !strings.HasPrefix(err.Error(), "Old entry") {
+		recordUndo(undoStep{Op: undoRestoreEntry, Entry: &entry})
+
 		// Remove the original time entry and append
 		if index < len(adata.TimeEntries)-1 {
 			adata.TimeEntries = append(adata.TimeEntries[:index], adata.TimeEntries[index+1:]...)
@@ -516,7 +980,7 @@ func unstopTimeEntry(id int) (newEntry toggl.TimeEntry, err error) {
 		}
 	}
 
-	if err := alfred.SaveJSON(cacheFile, &cache); err != nil {
+	if err := saveCache(); err != nil {
 		dlog.Printf("Error saving cache: %s\n", err)
 	}
 
@@ -524,18 +988,34 @@ func unstopTimeEntry(id int) (newEntry toggl.TimeEntry, err error) {
 }
 
 func updateTimeEntry(entryIn toggl.TimeEntry) (entry toggl.TimeEntry, err error) {
-	session := toggl.OpenSession(config.APIKey)
+	original, _, hasOriginal := getTimerByID(entryIn.ID)
+
+	if isQueueing() {
+		return queueEntryUpdate(entryIn), nil
+	}
 
-	if entry, err = session.UpdateTimeEntry(entryIn); err != nil {
+	if entry, err = putTimeEntry(entryIn); isOfflineError(err) {
+		return queueEntryUpdate(entryIn), nil
+	} else if err != nil {
 		return
 	}
 
+	if hasOriginal {
+		if original.IsRunning() && !entry.IsRunning() {
+			recordUndo(undoStep{Op: undoUnstopEntry, Entry: &entry})
+		} else {
+			recordUndo(undoStep{Op: undoRevertEntry, Entry: &original})
+		}
+	}
+
+	updateHistoryEntry(entry, false)
+
 	adata := &cache.Account
 
 	for i, e := range adata.TimeEntries {
 		if e.ID == entry.ID {
 			adata.TimeEntries[i] = entry
-			if err := alfred.SaveJSON(cacheFile, &cache); err != nil {
+			if err := saveCache(); err != nil {
 				dlog.Printf("Error saving cache: %v\n", err)
 			}
 			break
@@ -545,6 +1025,427 @@ func updateTimeEntry(entryIn toggl.TimeEntry) (entry toggl.TimeEntry, err error)
 	return
 }
 
+// splitTimeEntry cuts a time entry in two. The original entry ends at the
+// split time and a new entry, which runs if the original was running, starts
+// there.
+func splitTimeEntry(split splitCfg) (second toggl.TimeEntry, err error) {
+	var entry toggl.TimeEntry
+	var ok bool
+	if entry, _, ok = getTimerByID(split.Timer); !ok {
+		err = fmt.Errorf(`Time entry %d does not exist`, split.Timer)
+		return
+	}
+
+	desc := split.Second
+	if desc.Start == nil || !isWithinTimeEntry(entry, *desc.Start) {
+		err = fmt.Errorf("The split time must be within the time entry")
+		return
+	}
+
+	if entry.IsRunning() {
+		// Starting a backdated timer stops the running one at its start
+		return startTimeEntry(desc)
+	}
+
+	// The second part is created before the first is shortened so that a
+	// failure doesn't lose the time after the split
+	first := entry.Copy()
+	if err = first.SetStopTime(*desc.Start); err != nil {
+		return
+	}
+
+	desc.Stop = entry.Stop
+	if second, err = logTimeEntry(desc); err != nil {
+		return
+	}
+
+	_, err = updateTimeEntry(first)
+	return
+}
+
+// mergeTimeEntries collapses a group of time entries into the latest one,
+// which is extended back to the earliest start time. The remaining entries are
+// deleted.
+func mergeTimeEntries(ids []int) (merged toggl.TimeEntry, err error) {
+	var entries []toggl.TimeEntry
+	for _, id := range ids {
+		entry, _, ok := getTimerByID(id)
+		if !ok {
+			err = fmt.Errorf(`Time entry %d does not exist`, id)
+			return
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) < 2 {
+		err = fmt.Errorf("At least two time entries are needed for a merge")
+		return
+	}
+
+	sort.Sort(byTime(entries))
+	last := entries[len(entries)-1]
+
+	toUpdate := last.Copy()
+	toUpdate.SetStartTime(entries[0].StartTime(), false)
+	for _, entry := range entries[:len(entries)-1] {
+		for _, tag := range entry.Tags {
+			toUpdate.AddTag(tag)
+		}
+	}
+
+	if merged, err = updateTimeEntry(toUpdate); err != nil {
+		return
+	}
+
+	for _, entry := range entries[:len(entries)-1] {
+		if _, err = deleteTimeEntry(entry.ID); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// findMergeGroups finds runs of consecutive time entries on the same day with
+// the same description and project, as left behind by stopping and continuing
+// a timer. Each group is a list of time entry IDs.
+func findMergeGroups(entries []toggl.TimeEntry) (groups [][]int) {
+	sorted := make([]toggl.TimeEntry, len(entries))
+	copy(sorted, entries)
+	sort.Sort(byTime(sorted))
+
+	sameTask := func(a, b toggl.TimeEntry) bool {
+		return a.Description == b.Description &&
+			((a.Pid == nil && b.Pid == nil) || (a.Pid != nil && b.Pid != nil && *a.Pid == *b.Pid)) &&
+			isSameDate(a.StartTime().Local(), b.StartTime().Local())
+	}
+
+	var group []int
+	for i, entry := range sorted {
+		if i > 0 && sameTask(sorted[i-1], entry) {
+			group = append(group, entry.ID)
+			continue
+		}
+		if len(group) > 1 {
+			groups = append(groups, group)
+		}
+		group = []int{entry.ID}
+	}
+	if len(group) > 1 {
+		groups = append(groups, group)
+	}
+
+	return
+}
+
+// findMergeCandidates returns the cached time entries with the same
+// description and project as an entry, other than the entry itself and any
+// already selected ones, latest first
+func findMergeCandidates(entry toggl.TimeEntry, selected []int) (candidates []toggl.TimeEntry) {
+	for _, other := range cache.Account.TimeEntries {
+		if other.ID == entry.ID || containsInt(selected, other.ID) ||
+			other.Description != entry.Description || (entry.Pid == nil) != (other.Pid == nil) ||
+			(entry.Pid != nil && *entry.Pid != *other.Pid) {
+			continue
+		}
+		candidates = append(candidates, other)
+	}
+	sort.Sort(sort.Reverse(byTime(candidates)))
+	return
+}
+
+// mergePickerItems lists the entries that can be merged with a time entry.
+// Actioning one merges it with the entries selected so far; holding Cmd adds it
+// to the selection instead.
+func mergePickerItems(entry toggl.TimeEntry, selected []int, arg string) (items []alfred.Item) {
+	for _, other := range findMergeCandidates(entry, selected) {
+		start := other.StartTime().Local()
+		title := fmt.Sprintf("%s, %s to ", toHumanDateString(start), start.Format("3:04pm"))
+		if other.IsRunning() {
+			title += "now"
+		} else {
+			title += other.StopTime().Local().Format("3:04pm")
+		}
+
+		if !alfred.FuzzyMatches(title, arg) {
+			continue
+		}
+
+		group := append(append([]int{}, selected...), other.ID)
+		item := alfred.Item{
+			Title:    title,
+			Subtitle: fmt.Sprintf("Merge %d entries for this task into one", len(group)),
+			Icon:     "off.png",
+			Arg: &alfred.ItemArg{
+				Keyword: "timers",
+				Mode:    alfred.ModeDo,
+				Data:    alfred.Stringify(timerCfg{ToMerge: [][]int{group}}),
+			},
+		}
+		if other.IsRunning() {
+			item.Icon = "icon.png"
+		}
+
+		item.AddMod(alfred.ModCmd, alfred.ItemMod{
+			Subtitle: "Select this entry and choose more",
+			Arg: &alfred.ItemArg{
+				Keyword: "timers",
+				Mode:    alfred.ModeTell,
+				Data:    alfred.Stringify(timerCfg{Timer: &entry.ID, MergeWith: group}),
+			},
+		})
+
+		items = append(items, item)
+	}
+
+	if len(selected) > 1 && arg == "" {
+		items = alfred.InsertItem(items, alfred.Item{
+			Title:    fmt.Sprintf("Merge the %d selected entries", len(selected)),
+			Subtitle: "The merged entry spans from the first entry's start to the last entry's end",
+			Arg: &alfred.ItemArg{
+				Keyword: "timers",
+				Mode:    alfred.ModeDo,
+				Data:    alfred.Stringify(timerCfg{ToMerge: [][]int{selected}}),
+			},
+		}, 0)
+	}
+
+	if len(items) == 0 {
+		items = append(items, alfred.Item{Title: "No other entries for this task"})
+	}
+
+	return
+}
+
+// getSplit creates a split configuration from a query like "11:30 new
+// description @Project #tag $". The second part of the split keeps the entry's
+// description, project, tags, and billable flag unless new ones are given.
+func getSplit(entry *toggl.TimeEntry, query string) (split splitCfg, err error) {
+	timeStr, rest := alfred.SplitCmd(query)
+
+	var clock time.Time
+	if clock, err = parseClockTime(timeStr); err != nil {
+		return
+	}
+
+	start := entry.StartTime().Local()
+	at := getNewTime(start, clock)
+	if !at.After(start) {
+		at = at.AddDate(0, 0, 1)
+	}
+	if !isWithinTimeEntry(*entry, at) {
+		err = fmt.Errorf("%s is not within this time entry", timeStr)
+		return
+	}
+
+	q, words := splitQuickEntry(rest)
+	q.Description = strings.Join(words, " ")
+	override := newStartDesc(q)
+
+	second := startDesc{
+		Description: entry.Description,
+		Tags:        entry.Tags,
+		Billable:    entry.Billable,
+		Start:       &at,
+	}
+
+	if entry.Pid != nil {
+		second.Pid = *entry.Pid
+	}
+
+	if override.Description != "" {
+		second.Description = override.Description
+	}
+
+	if override.Pid != 0 || override.NewProject != "" {
+		second.Pid = override.Pid
+		second.NewProject = override.NewProject
+	}
+
+	if len(override.Tags) > 0 || len(override.NewTags) > 0 {
+		second.Tags = override.Tags
+		second.NewTags = override.NewTags
+	}
+
+	if override.Billable {
+		second.Billable = true
+	}
+
+	split = splitCfg{Timer: entry.ID, Second: second}
+	return
+}
+
+// isWithinTimeEntry returns true if a time falls strictly between a time
+// entry's start and stop times
+func isWithinTimeEntry(entry toggl.TimeEntry, t time.Time) bool {
+	if !t.After(entry.StartTime()) {
+		return false
+	}
+	if entry.IsRunning() {
+		return t.Before(time.Now())
+	}
+	return t.Before(entry.StopTime())
+}
+
+// getLogQuery returns the part of a timers query following a leading "log"
+func getLogQuery(arg string) (query string, ok bool) {
+	head, tail := alfred.SplitCmd(arg)
+	if strings.ToLower(head) == "log" && tail != "" {
+		return tail, true
+	}
+	return
+}
+
+var logDurationFormat = regexp.MustCompile(`^(\d+(\.\d+)?h)?(\d+m)?$`)
+
+// parseLogSuffix looks for the time span of a completed entry at the end of a
+// list of words. The span may be a time range ("9:00-10:30") or a duration
+// ("1h15m"), optionally preceded or followed by a day ("yesterday"). A
+// duration for the current day ends now; a duration on an earlier day starts
+// when the last entry on that day ended, or at 9:00 if the day is empty.
+func parseLogSuffix(words []string, now time.Time) (rest []string, start, stop time.Time, err error) {
+	day := toDayStart(now)
+	hasDay := false
+
+	n := len(words)
+	if n > 1 {
+		if day, hasDay = parseDay(words[n-1], now); hasDay {
+			n--
+		} else {
+			day = toDayStart(now)
+		}
+	}
+
+	if n == 0 {
+		err = fmt.Errorf("Missing time range or duration")
+		return
+	}
+
+	spanWord := strings.ToLower(words[n-1])
+	n--
+
+	if n > 0 && !hasDay {
+		if d, ok := parseDay(words[n-1], now); ok {
+			day = d
+			n--
+		}
+	}
+
+	rest = words[:n]
+
+	if parts := strings.SplitN(spanWord, "-", 2); len(parts) == 2 {
+		var startClock, stopClock time.Time
+		if startClock, err = parseClockTime(parts[0]); err != nil {
+			return
+		}
+		if stopClock, err = parseClockTime(parts[1]); err != nil {
+			return
+		}
+
+		start = getNewTime(day, startClock)
+		stop = getNewTime(day, stopClock)
+		if !stop.After(start) {
+			stop = stop.AddDate(0, 0, 1)
+		}
+	} else if spanWord != "" && logDurationFormat.MatchString(spanWord) {
+		var duration time.Duration
+		if duration, err = time.ParseDuration(spanWord); err != nil {
+			return
+		}
+
+		if isSameDate(day, now) {
+			stop = now.Local().Truncate(time.Minute)
+			start = stop.Add(-duration)
+		} else {
+			start = getNewTime(day, time.Date(0, 1, 1, 9, 0, 0, 0, time.UTC))
+			for _, entry := range cache.Account.TimeEntries {
+				entryStop := entry.StopTime().Local()
+				if !entry.IsRunning() && isSameDate(entryStop, day) && entryStop.After(start) {
+					start = entryStop
+				}
+			}
+			stop = start.Add(duration)
+		}
+	} else {
+		err = fmt.Errorf("Invalid time range or duration %s", spanWord)
+		return
+	}
+
+	if stop.After(now) {
+		err = fmt.Errorf("A logged time entry can't end in the future")
+	}
+
+	return
+}
+
+// logEntryItems returns an item for logging a completed time entry described
+// by a query
+func logEntryItems(query string) (items []alfred.Item, err error) {
+	q, words := splitQuickEntry(query)
+
+	words, start, stop, parseErr := parseLogSuffix(words, time.Now())
+	if parseErr != nil {
+		subtitle := "Enter a description followed by a time range (9:00-10:30) or duration (1h15m)"
+		if query != "" {
+			subtitle = parseErr.Error()
+		}
+		items = append(items, alfred.Item{
+			Title:    "Log: " + query,
+			Subtitle: subtitle,
+		})
+		return
+	}
+
+	q.Description = strings.Join(words, " ")
+	toLog := newStartDesc(q)
+	toLog.Start = &start
+	toLog.Stop = &stop
+
+	if toLog.Pid == 0 && toLog.NewProject == "" && config.DefaultProjectID != 0 {
+		toLog.Pid = config.DefaultProjectID
+	}
+
+	duration := stop.Sub(start).Hours()
+	subtitle := fmt.Sprintf("Log %s, %s from %s to %s", formatDuration(round(duration*100.0)),
+		toHumanDateString(start), start.Format("15:04"), stop.Format("15:04"))
+
+	if toLog.Pid != 0 {
+		project, _, _ := getProjectByID(toLog.Pid)
+		subtitle = "[" + project.Name + "] " + subtitle
+	} else if toLog.NewProject != "" {
+		subtitle = "[" + toLog.NewProject + "] " + subtitle
+	}
+
+	var tags []string
+	tags = append(tags, toLog.Tags...)
+	tags = append(tags, toLog.NewTags...)
+	if len(tags) > 0 {
+		subtitle += ", tagged " + strings.Join(tags, ", ")
+	}
+
+	if toLog.Billable {
+		subtitle += ", billable"
+	}
+
+	title := toLog.Description
+	if title == "" {
+		title = "<No description>"
+	}
+
+	items = append(items, alfred.Item{
+		Title:    title,
+		Subtitle: subtitle,
+		Icon:     "off.png",
+		Arg: &alfred.ItemArg{
+			Keyword: "timers",
+			Mode:    alfred.ModeDo,
+			Data:    alfred.Stringify(timerCfg{ToLog: &toLog}),
+		},
+	})
+
+	return
+}
+
 func getNewTime(original, new time.Time) time.Time {
 	originalMinutes := original.Hour()*60 + original.Minute()
 	newMinutes := new.Hour()*60 + new.Minute()
@@ -552,6 +1453,16 @@ func getNewTime(original, new time.Time) time.Time {
 	return original.Add(delta)
 }
 
+// formatEntryTime formats a new start or stop time for a time entry, including
+// the date if it differs from the original time's date
+func formatEntryTime(t, original time.Time) string {
+	t = t.Local()
+	if isSameDate(t, original.Local()) {
+		return t.Format("15:04")
+	}
+	return toIsoDateString(t) + " " + t.Format("15:04")
+}
+
 func timeEntryItems(entry *toggl.TimeEntry, query string) (items []alfred.Item, err error) {
 	parts := alfred.CleanSplitN(query, " ", 2)
 
@@ -598,7 +1509,9 @@ func timeEntryItems(entry *toggl.TimeEntry, query string) (items []alfred.Item,
 					clientName = client.Name
 				}
 
-				if proj.IsActive() && alfred.FuzzyMatches(proj.Name+clientName, name) {
+				// Entries can only be moved between projects in their own workspace
+				if proj.IsActive() && proj.Wid == entry.Wid &&
+					alfred.FuzzyMatches(proj.Name+clientName, name) {
 					updateEntry := entry.Copy()
 
 					if entry.Pid != nil && *entry.Pid == proj.ID {
@@ -607,6 +1520,9 @@ func timeEntryItems(entry *toggl.TimeEntry, query string) (items []alfred.Item,
 						updateEntry.Pid = &proj.ID
 					}
 
+					// A task belongs to a single project
+					updateEntry.Tid = nil
+
 					item := alfred.Item{
 						UID:          fmt.Sprintf("%s.project.%d", workflow.BundleID(), proj.ID),
 						Title:        proj.Name,
@@ -644,6 +1560,66 @@ func timeEntryItems(entry *toggl.TimeEntry, query string) (items []alfred.Item,
 		}
 	}
 
+	var tasks []projectTask
+	if entry.Pid != nil {
+		tasks = findTasksByProjectID(*entry.Pid)
+	}
+
+	if len(tasks) > 0 && alfred.FuzzyMatches("task:", parts[0]) {
+		command := "Task"
+
+		if strings.ToLower(parts[0]) == "task:" {
+			var name string
+
+			if len(parts) > 1 {
+				name = parts[1]
+			}
+
+			for _, task := range tasks {
+				if alfred.FuzzyMatches(task.Name, name) {
+					updateEntry := entry.Copy()
+					isCurrent := entry.Tid != nil && *entry.Tid == task.ID
+
+					if isCurrent {
+						updateEntry.Tid = nil
+					} else {
+						id := task.ID
+						updateEntry.Tid = &id
+					}
+
+					item := alfred.Item{
+						UID:          fmt.Sprintf("%s.task.%d", workflow.BundleID(), task.ID),
+						Title:        task.Name,
+						Autocomplete: command + ": " + task.Name,
+						Arg: &alfred.ItemArg{
+							Keyword: "timers",
+							Mode:    alfred.ModeDo,
+							Data:    alfred.Stringify(timerCfg{ToUpdate: &updateEntry}),
+						},
+					}
+					item.AddCheckBox(isCurrent)
+					items = append(items, item)
+				}
+			}
+
+			alfred.FuzzySort(items, name)
+		} else {
+			item := alfred.Item{
+				Title:        command + ": <None>",
+				Subtitle:     "Change the task this entry is assigned to",
+				Autocomplete: command + ": ",
+			}
+
+			if entry.Tid != nil {
+				if task, _, ok := getTaskByID(*entry.Tid); ok {
+					item.Title = command + ": " + task.Name
+				}
+			}
+
+			items = append(items, item)
+		}
+	}
+
 	if alfred.FuzzyMatches("tags:", parts[0]) {
 		command := "Tags"
 
@@ -655,7 +1631,7 @@ func timeEntryItems(entry *toggl.TimeEntry, query string) (items []alfred.Item,
 			}
 
 			for _, tag := range cache.Account.Tags {
-				if alfred.FuzzyMatches(tag.Name, tagName) {
+				if tag.Wid == entry.Wid && alfred.FuzzyMatches(tag.Name, tagName) {
 					item := alfred.Item{
 						Title:        tag.Name,
 						Autocomplete: tag.Name,
@@ -729,13 +1705,11 @@ func timeEntryItems(entry *toggl.TimeEntry, query string) (items []alfred.Item,
 		if len(parts) > 1 {
 			timeStr := parts[1]
 
-			if newTime, err := time.Parse("15:04", timeStr); err == nil {
-				newStart := getNewTime(entry.StartTime().Local(), newTime)
-
+			if newStart, err := parseDateTime(timeStr, entry.StartTime()); err == nil {
 				updateTimer := entry.Copy()
 				updateTimer.SetStartTime(newStart, true)
 
-				item.Title = command + ": " + timeStr
+				item.Title = command + ": " + formatEntryTime(newStart, entry.StartTime())
 				item.Subtitle = "Press enter to change start time"
 				if !entry.IsRunning() {
 					item.Subtitle += " (end time will also be adjusted)"
@@ -787,13 +1761,11 @@ func timeEntryItems(entry *toggl.TimeEntry, query string) (items []alfred.Item,
 			if len(parts) > 1 {
 				timeStr := parts[1]
 
-				if newTime, err := time.Parse("15:04", timeStr); err == nil {
-					newStop := getNewTime(entry.StopTime().Local(), newTime)
-
+				if newStop, err := parseDateTime(timeStr, entry.StopTime()); err == nil {
 					updateTimer := entry.Copy()
 					updateTimer.SetStopTime(newStop)
 
-					item.Title = command + ": " + timeStr
+					item.Title = command + ": " + formatEntryTime(newStop, entry.StopTime())
 					item.Subtitle = "Press enter to change stop time"
 					item.Arg = &alfred.ItemArg{
 						Keyword: "timers",
@@ -808,6 +1780,39 @@ func timeEntryItems(entry *toggl.TimeEntry, query string) (items []alfred.Item,
 			items = append(items, item)
 		}
 
+		if alfred.FuzzyMatches("date:", parts[0]) {
+			command := "Date"
+			start := entry.StartTime().Local()
+
+			item := alfred.Item{
+				Title:        command + ": " + toIsoDateString(start),
+				Autocomplete: command + ": ",
+				Subtitle:     "Move this entry to another day",
+			}
+
+			if len(parts) > 1 {
+				if day, ok := parseDay(parts[1], time.Now()); ok {
+					newStart := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(),
+						start.Minute(), start.Second(), start.Nanosecond(), time.Local)
+
+					updateTimer := entry.Copy()
+					updateTimer.SetStartTime(newStart, true)
+
+					item.Title = command + ": " + toIsoDateString(newStart)
+					item.Subtitle = "Press enter to move this entry to " + toHumanDateString(newStart)
+					item.Arg = &alfred.ItemArg{
+						Keyword: "timers",
+						Mode:    alfred.ModeDo,
+						Data:    alfred.Stringify(timerCfg{ToUpdate: &updateTimer}),
+					}
+				} else {
+					dlog.Printf("Invalid date: %s\n", parts[1])
+				}
+			}
+
+			items = append(items, item)
+		}
+
 		if alfred.FuzzyMatches("duration:", parts[0]) {
 			command := "Duration"
 			duration := float64(entry.Duration) / 60.0 / 60.0
@@ -940,6 +1945,79 @@ func timeEntryItems(entry *toggl.TimeEntry, query string) (items []alfred.Item,
 		}
 	}
 
+	if alfred.FuzzyMatches("split:", parts[0]) {
+		command := "Split"
+
+		item := alfred.Item{
+			Title:        command + ": ",
+			Autocomplete: command + ": ",
+			Subtitle:     "Split this entry in two at a given time",
+		}
+
+		if len(parts) > 1 {
+			if split, err := getSplit(entry, parts[1]); err == nil {
+				second := split.Second
+				at := second.Start.Local()
+
+				item.Title = command + ": " + at.Format("15:04")
+				item.Subtitle = fmt.Sprintf(`Press enter to split; the second part will be "%s"`,
+					second.Description)
+				if second.Pid != 0 {
+					project, _, _ := getProjectByID(second.Pid)
+					item.Subtitle += " in " + project.Name
+				} else if second.NewProject != "" {
+					item.Subtitle += " in " + second.NewProject
+				}
+				if tags := append(append([]string{}, second.Tags...), second.NewTags...); len(tags) > 0 {
+					item.Subtitle += ", tagged " + strings.Join(tags, ", ")
+				}
+				if second.Billable {
+					item.Subtitle += ", billable"
+				}
+				item.Arg = &alfred.ItemArg{
+					Keyword: "timers",
+					Mode:    alfred.ModeDo,
+					Data:    alfred.Stringify(timerCfg{ToSplit: &split}),
+				}
+			} else {
+				item.Subtitle = err.Error()
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	if alfred.FuzzyMatches("merge", query) {
+		for _, group := range findMergeGroups(cache.Account.TimeEntries) {
+			if containsInt(group, entry.ID) {
+				items = append(items, alfred.Item{
+					Title: "Merge",
+					Subtitle: fmt.Sprintf("Merge the %d consecutive entries for this task into one",
+						len(group)),
+					Arg: &alfred.ItemArg{
+						Keyword: "timers",
+						Mode:    alfred.ModeDo,
+						Data:    alfred.Stringify(timerCfg{ToMerge: [][]int{group}}),
+					},
+					Autocomplete: "Merge",
+				})
+				break
+			}
+		}
+
+		if len(findMergeCandidates(*entry, nil)) > 0 {
+			items = append(items, alfred.Item{
+				Title:    "Merge with...",
+				Subtitle: "Choose other entries for this task to merge with this one",
+				Arg: &alfred.ItemArg{
+					Keyword: "timers",
+					Mode:    alfred.ModeTell,
+					Data:    alfred.Stringify(timerCfg{Timer: &entry.ID, MergeWith: []int{entry.ID}}),
+				},
+			})
+		}
+	}
+
 	if alfred.FuzzyMatches("delete", query) {
 		items = append(items, alfred.Item{
 			Title:    "Delete",
@@ -36,7 +36,7 @@ func (c TokenCommand) Do(data string) (string, error) {
 	log.Printf("token: %s", token)
 
 	config.APIKey = token
-	err = alfred.SaveJSON(configFile, &config)
+	err = saveConfig()
 	if err != nil {
 		return "", err
 	}
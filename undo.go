@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jason0x43/go-alfred"
+	"github.com/jason0x43/go-toggl"
+)
+
+// UndoCommand is a command for reverting recent changes
+type UndoCommand struct{}
+
+// About returns information about this command
+func (c UndoCommand) About() alfred.CommandDef {
+	return alfred.CommandDef{
+		Keyword:     "undo",
+		Description: "Undo recent changes",
+		IsEnabled:   config.APIKey != "",
+	}
+}
+
+// Items returns a list of filter items
+func (c UndoCommand) Items(arg, data string) (items []alfred.Item, err error) {
+	journal := loadUndoJournal()
+
+	for i := len(journal) - 1; i >= 0; i-- {
+		action := journal[i]
+		item := alfred.Item{
+			Title: "Undo: " + action.Description,
+			Subtitle: fmt.Sprintf("Changed %s at %s", toHumanDateString(action.Time),
+				action.Time.Local().Format("15:04")),
+		}
+
+		if i == len(journal)-1 {
+			item.Arg = &alfred.ItemArg{
+				Keyword: "undo",
+				Mode:    alfred.ModeDo,
+			}
+		} else {
+			item.Subtitle += "; undo newer changes first"
+		}
+
+		if alfred.FuzzyMatches(item.Title, arg) {
+			items = append(items, item)
+		}
+	}
+
+	if len(items) == 0 {
+		items = append(items, alfred.Item{Title: "Nothing to undo"})
+	}
+
+	return
+}
+
+// Do runs the command
+func (c UndoCommand) Do(data string) (out string, err error) {
+	// The journal is locked while the undo runs so that actions recorded by
+	// other processes in the meantime aren't lost
+	unlock, err := lockFile(undoFile)
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	journal := loadUndoJournal()
+	if len(journal) == 0 {
+		return "Nothing to undo", nil
+	}
+
+	action := journal[len(journal)-1]
+	journal = journal[:len(journal)-1]
+
+	dlog.Printf("undoing %v", action)
+
+	// Inverse steps are run in the reverse of the order their changes were made
+	undoing = true
+	idMap := map[int]int{}
+	remaining := 0
+	for i := len(action.Steps) - 1; i >= 0; i-- {
+		if err = runUndoStep(action.Steps[i], idMap); err != nil {
+			remaining = i + 1
+			break
+		}
+	}
+	undoing = false
+
+	if remaining > 0 {
+		// Keep the steps that weren't run so the undo can be retried
+		action.Steps = action.Steps[:remaining]
+		journal = append(journal, action)
+	}
+
+	// Entries that were recreated have new IDs
+	remapUndoJournal(journal, idMap)
+
+	if err := writeJSON(undoFile, &journal); err != nil {
+		dlog.Printf("Error saving undo journal: %v\n", err)
+	}
+
+	if err != nil {
+		return
+	}
+
+	return "Undid: " + action.Description, nil
+}
+
+// support -------------------------------------------------------------------
+
+// maxUndoActions is the number of actions kept in the undo journal
+const maxUndoActions = 50
+
+type undoOp string
+
+const (
+	undoDeleteEntry   undoOp = "deleteEntry"
+	undoRestoreEntry  undoOp = "restoreEntry"
+	undoRevertEntry   undoOp = "revertEntry"
+	undoUnstopEntry   undoOp = "unstopEntry"
+	undoDeleteProject undoOp = "deleteProject"
+	undoRevertProject undoOp = "revertProject"
+	undoDeleteTag     undoOp = "deleteTag"
+	undoRestoreTag    undoOp = "restoreTag"
+)
+
+// undoAction is a user-level change, such as a merge, along with the steps
+// that will revert it
+type undoAction struct {
+	Time        time.Time  `json:"time"`
+	Description string     `json:"description"`
+	Steps       []undoStep `json:"steps"`
+}
+
+// undoStep is the inverse of a single change to remote data
+type undoStep struct {
+	Op       undoOp           `json:"op"`
+	Entry    *toggl.TimeEntry `json:"entry,omitempty"`
+	Project  *toggl.Project   `json:"project,omitempty"`
+	Tag      *toggl.Tag       `json:"tag,omitempty"`
+	Details  *projectDetails  `json:"details,omitempty"`
+	EntryIDs []int            `json:"entryIds,omitempty"`
+}
+
+// pendingUndo holds the inverse steps of the changes made by the current
+// action; undoing is true while an undo is being run so that its changes
+// aren't recorded
+var pendingUndo []undoStep
+var undoing bool
+
+// recordUndo adds an inverse step for the current action
+func recordUndo(step undoStep) {
+	if !undoing {
+		pendingUndo = append(pendingUndo, step)
+	}
+}
+
+// saveUndoAction adds the steps recorded by the current action to the undo
+// journal. It should be called when an action's Do method completes, even if
+// the action failed partway through.
+func saveUndoAction(description string, err error) {
+	if len(pendingUndo) == 0 {
+		return
+	}
+
+	if err != nil {
+		description = fmt.Sprintf("Partially completed change (%v)", err)
+	}
+
+	var journal []undoAction
+	if err := updateJSON(undoFile, &journal, func() {
+		journal = append(journal, undoAction{
+			Time:        time.Now(),
+			Description: description,
+			Steps:       pendingUndo,
+		})
+		if len(journal) > maxUndoActions {
+			journal = journal[len(journal)-maxUndoActions:]
+		}
+	}); err != nil {
+		dlog.Printf("Error saving undo journal: %v\n", err)
+	}
+	pendingUndo = nil
+}
+
+func loadUndoJournal() (journal []undoAction) {
+	if err := alfred.LoadJSON(undoFile, &journal); err != nil {
+		dlog.Printf("Error loading undo journal: %v\n", err)
+	}
+	return
+}
+
+// runUndoStep applies a single inverse step. IDs of recreated entries are
+// added to idMap.
+func runUndoStep(step undoStep, idMap map[int]int) (err error) {
+	session := toggl.OpenSession(config.APIKey)
+
+	entryID := func(id int) int {
+		if newID, ok := idMap[id]; ok {
+			return newID
+		}
+		return id
+	}
+
+	switch step.Op {
+	case undoDeleteEntry:
+		id := entryID(step.Entry.ID)
+		if _, _, ok := getTimerByID(id); ok {
+			_, err = deleteTimeEntry(id)
+		} else {
+			entry := *step.Entry
+			entry.ID = id
+			if _, err = session.DeleteTimeEntry(entry); err == nil {
+				updateHistoryEntry(entry, true)
+			}
+		}
+
+	case undoRestoreEntry:
+		entry := step.Entry.Copy()
+		entry.ID = 0
+		var restored toggl.TimeEntry
+		if restored, err = addTimeEntry(entry); err == nil {
+			idMap[step.Entry.ID] = restored.ID
+		}
+
+	case undoRevertEntry:
+		entry := step.Entry.Copy()
+		entry.ID = entryID(entry.ID)
+		_, err = updateTimeEntry(entry)
+
+	case undoUnstopEntry:
+		id := entryID(step.Entry.ID)
+		var restored toggl.TimeEntry
+		if restored, err = unstopTimeEntry(id); err == nil {
+			idMap[step.Entry.ID] = restored.ID
+		}
+
+	case undoDeleteProject:
+		if _, err = session.DeleteProject(*step.Project); err == nil {
+			if _, index, ok := getProjectByID(step.Project.ID); ok {
+				adata := &cache.Account
+				adata.Projects = append(adata.Projects[:index], adata.Projects[index+1:]...)
+			}
+		}
+
+	case undoRevertProject:
+		project := *step.Project
+		_, err = updateProject(&project, step.Details)
+
+	case undoDeleteTag:
+		if _, err = session.DeleteTag(*step.Tag); err == nil {
+			if _, index, ok := getTagByID(step.Tag.ID); ok {
+				adata := &cache.Account
+				adata.Tags = append(adata.Tags[:index], adata.Tags[index+1:]...)
+			}
+		}
+
+	case undoRestoreTag:
+		if _, err = createTag(&createTagMessage{Name: step.Tag.Name, WID: step.Tag.Wid}); err != nil {
+			return
+		}
+		for _, id := range step.EntryIDs {
+			var entry toggl.TimeEntry
+			if entry, err = session.AddRemoveTag(entryID(id), step.Tag.Name, true,
+				step.Tag.Wid); err != nil {
+				return
+			}
+			if _, index, ok := getTimerByID(entry.ID); ok {
+				cache.Account.TimeEntries[index] = entry
+			}
+		}
+
+	default:
+		err = fmt.Errorf("Unknown undo step %s", step.Op)
+	}
+
+	if err == nil {
+		if err := saveCache(); err != nil {
+			dlog.Printf("Error saving cache: %s\n", err)
+		}
+	}
+
+	return
+}
+
+// remapUndoJournal updates the entry IDs in the journal after entries have
+// been recreated
+func remapUndoJournal(journal []undoAction, idMap map[int]int) {
+	for i := range journal {
+		for j := range journal[i].Steps {
+			step := &journal[i].Steps[j]
+			if step.Entry != nil {
+				if newID, ok := idMap[step.Entry.ID]; ok {
+					step.Entry.ID = newID
+				}
+			}
+			for k, id := range step.EntryIDs {
+				if newID, ok := idMap[id]; ok {
+					step.EntryIDs[k] = newID
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jason0x43/go-alfred"
+)
+
+// WorkspaceCommand is a command for selecting the active workspace
+type WorkspaceCommand struct{}
+
+// About returns information about this command
+func (c WorkspaceCommand) About() alfred.CommandDef {
+	return alfred.CommandDef{
+		Keyword:     "workspaces",
+		Description: "List and switch workspaces",
+		IsEnabled:   config.APIKey != "",
+	}
+}
+
+// Items returns a list of filter items
+func (c WorkspaceCommand) Items(arg, data string) (items []alfred.Item, err error) {
+	if err = checkRefresh(); err != nil {
+		return
+	}
+
+	for _, workspace := range cache.Account.Workspaces {
+		if !alfred.FuzzyMatches(workspace.Name, arg) {
+			continue
+		}
+
+		var projects, tags int
+		for _, project := range cache.Account.Projects {
+			if project.Wid == workspace.ID && project.IsActive() {
+				projects++
+			}
+		}
+		for _, tag := range cache.Account.Tags {
+			if tag.Wid == workspace.ID {
+				tags++
+			}
+		}
+
+		id := workspace.ID
+		item := alfred.Item{
+			UID:          fmt.Sprintf("%s.workspace.%d", workflow.BundleID(), workspace.ID),
+			Title:        workspace.Name,
+			Subtitle:     fmt.Sprintf("%d projects, %d tags", projects, tags),
+			Autocomplete: workspace.Name,
+			Arg: &alfred.ItemArg{
+				Keyword: "workspaces",
+				Mode:    alfred.ModeDo,
+				Data:    alfred.Stringify(workspaceCfg{ToActivate: &id}),
+			},
+		}
+		item.AddCheckBox(workspace.ID == cache.Workspace)
+
+		items = append(items, item)
+	}
+
+	if alfred.FuzzyMatches("show all workspaces", arg) {
+		showAll := !config.AllWorkspaces
+		item := alfred.Item{
+			Title:    "Show all workspaces",
+			Subtitle: "List projects, tags and timers from every workspace",
+			Arg: &alfred.ItemArg{
+				Keyword: "workspaces",
+				Mode:    alfred.ModeDo,
+				Data:    alfred.Stringify(workspaceCfg{ShowAll: &showAll}),
+			},
+		}
+		item.AddCheckBox(config.AllWorkspaces)
+		items = append(items, item)
+	}
+
+	if len(items) == 0 {
+		items = append(items, alfred.Item{Title: "No matching workspaces"})
+	}
+
+	return
+}
+
+// Do runs the command
+func (c WorkspaceCommand) Do(data string) (out string, err error) {
+	var cfg workspaceCfg
+
+	if data != "" {
+		if err = json.Unmarshal([]byte(data), &cfg); err != nil {
+			dlog.Printf("Error unmarshaling workspace data: %v", err)
+			return
+		}
+	}
+
+	if cfg.ToActivate != nil {
+		workspace, _, ok := getWorkspaceByID(*cfg.ToActivate)
+		if !ok {
+			return "", fmt.Errorf("Invalid workspace ID %d", *cfg.ToActivate)
+		}
+
+		config.WorkspaceID = workspace.ID
+		cache.Workspace = workspace.ID
+		out = fmt.Sprintf(`Switched to workspace "%s"`, workspace.Name)
+	} else if cfg.ShowAll != nil {
+		config.AllWorkspaces = *cfg.ShowAll
+		if config.AllWorkspaces {
+			out = "Showing all workspaces"
+		} else {
+			out = "Showing the active workspace"
+		}
+	} else {
+		return "", fmt.Errorf("Unrecognized input: %s", data)
+	}
+
+	if err = saveConfig(); err != nil {
+		dlog.Printf("Error saving config: %s\n", err)
+		return "", err
+	}
+	if err := saveCache(); err != nil {
+		dlog.Printf("Error saving cache: %s\n", err)
+	}
+
+	return
+}
+
+// support -------------------------------------------------------------------
+
+type workspaceCfg struct {
+	ToActivate *int  `json:"activate,omitempty"`
+	ShowAll    *bool `json:"showAll,omitempty"`
+}